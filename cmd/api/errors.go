@@ -1,10 +1,16 @@
 package main
 
 import (
+	"expvar"
+	"finalproject/internal/data"
 	"fmt"
 	"net/http"
 )
 
+// Count the requests which failed because the database connection pool was exhausted.
+// The value is served by debugVarsHandler.
+var poolTimeouts = expvar.NewInt("database_pool_timeouts")
+
 // The logError() method is a generic helper for logging an error message. Later in the
 // book we'll upgrade this to use structured logging, and record additional information
 // about the request including the HTTP method and URL.
@@ -30,11 +36,28 @@ func (app *application) errorResponse(w http.ResponseWriter, r *http.Request, st
 // errorResponse() helper to send a 500 Internal Server Error status code and JSON
 // response (containing a generic error message) to the client.
 func (app *application) serverErrorResponse(w http.ResponseWriter, r *http.Request, err error) {
+	// If the error was caused by the database connection pool being exhausted, we're
+	// overloaded rather than broken, so tell the client to try again later instead.
+	if data.IsPoolTimeout(err) {
+		app.overloadedResponse(w, r, err)
+		return
+	}
 	app.logError(r, err)
 	message := "the server encountered a problem and could not process your request"
 	app.errorResponse(w, r, http.StatusInternalServerError, message)
 }
 
+// The overloadedResponse() method will be used when we couldn't get a database
+// connection in time. It logs the error, increments the database_pool_timeouts metric,
+// and sends a 503 Service Unavailable status code with a Retry-After header.
+func (app *application) overloadedResponse(w http.ResponseWriter, r *http.Request, err error) {
+	app.logError(r, err)
+	poolTimeouts.Add(1)
+	w.Header().Set("Retry-After", "1")
+	message := "the server is currently overloaded, please try again later"
+	app.errorResponse(w, r, http.StatusServiceUnavailable, message)
+}
+
 // The notFoundResponse() method will be used to send a 404 Not Found status code and
 // JSON response to the client.
 func (app *application) notFoundResponse(w http.ResponseWriter, r *http.Request) {
@@ -20,3 +20,16 @@ func (app *application) healthcheckHandler(w http.ResponseWriter, r *http.Reques
 		app.serverErrorResponse(w, r, err)
 	}
 }
+
+// Declare a handler which writes the application metrics. We deliberately don't use
+// expvar.Handler() here, because it also publishes the command line, which includes
+// secrets such as the database DSN and SMTP password.
+func (app *application) debugVarsHandler(w http.ResponseWriter, r *http.Request) {
+	env := envelope{
+		"database_pool_timeouts": poolTimeouts.Value(),
+	}
+	err := app.writeJSON(w, http.StatusOK, env, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
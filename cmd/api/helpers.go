@@ -1,9 +1,9 @@
 package main
 
 import (
-	"awesomeProject2/internal/validator"
 	"encoding/json"
 	"errors"
+	"finalproject/internal/validator"
 	"fmt"
 	"github.com/julienschmidt/httprouter"
 	"io"
@@ -24,6 +24,22 @@ func (app *application) readIDParam(r *http.Request) (int64, error) {
 	return id, nil
 }
 
+// The readIDOrUUIDParam() helper reads the "id" URL parameter, which may be either an
+// internal integer ID or a public UUID. Exactly one of the returned id and uuid values
+// is set. If the parameter is neither, it returns an error.
+func (app *application) readIDOrUUIDParam(r *http.Request) (int64, string, error) {
+	params := httprouter.ParamsFromContext(r.Context())
+	param := params.ByName("id")
+	if validator.Matches(param, validator.UUIDRX) {
+		return 0, strings.ToLower(param), nil
+	}
+	id, err := strconv.ParseInt(param, 10, 64)
+	if err != nil || id < 1 {
+		return 0, "", errors.New("invalid id parameter")
+	}
+	return id, "", nil
+}
+
 // Define an envelope type.
 type envelope map[string]any
 
@@ -132,6 +148,22 @@ func (app *application) readCSV(qs url.Values, key string, defaultValue []string
 	return strings.Split(csv, ",")
 }
 
+// The readPrefixed() helper returns the query string values whose keys start with the
+// given prefix, keyed by the rest of the key. For example, with the prefix "spec." the
+// query string ?spec.RAM=8GB returns map[RAM:8GB]. Keys with nothing after the prefix
+// are ignored.
+func (app *application) readPrefixed(qs url.Values, prefix string) map[string]string {
+	values := make(map[string]string)
+	for key := range qs {
+		name := strings.TrimPrefix(key, prefix)
+		if name == key || name == "" {
+			continue
+		}
+		values[name] = qs.Get(key)
+	}
+	return values
+}
+
 // The readInt() helper reads a string value from the query string and converts it to an
 // integer before returning. If no matching key could be found it returns the provided
 // default value. If the value couldn't be converted to an integer, then we record an
@@ -2,14 +2,18 @@ package main
 
 import (
 	"context" // New import
+	"errors"
 	"finalproject/internal/data"
 	"finalproject/internal/jsonlog"
 	"finalproject/internal/mailer"
 	"flag"
 	"fmt"
 	"github.com/jackc/pgx/v5/pgxpool"
+	"net"
 	"net/http"
 	"os"
+	"strconv"
+	"strings"
 	"sync"
 	"time"
 )
@@ -31,9 +35,26 @@ type config struct {
 		maxIdleTime  string
 	}
 	limiter struct {
-		enabled bool
-		rps     float64
-		burst   int
+		enabled     bool
+		rps         float64
+		burst       int
+		exemptNets  []*net.IPNet
+		exemptPaths []string
+		routes      []routeLimit
+	}
+	compression struct {
+		enabled      bool
+		minSize      int
+		contentTypes []string
+	}
+	products struct {
+		uniqueTitlePerOwner bool
+	}
+	views struct {
+		flushInterval time.Duration
+	}
+	feed struct {
+		items int
 	}
 	smtp struct {
 		host     string
@@ -76,6 +97,61 @@ func main() {
 	flag.Float64Var(&cfg.limiter.rps, "limiter-rps", 2, "Rate limiter maximum requests per second")
 	flag.IntVar(&cfg.limiter.burst, "limiter-burst", 4, "Rate limiter maximum burst")
 	flag.BoolVar(&cfg.limiter.enabled, "limiter-enabled", true, "Enable rate limiter")
+	// Internal services and health checks shouldn't be throttled, so allow a set of
+	// client networks and request paths to bypass the rate limiter. The networks are
+	// parsed here so that a malformed CIDR stops the application at startup.
+	flag.Func("limiter-exempt-ips", "Rate limiter exempt IPs or CIDRs (comma separated)", func(val string) error {
+		nets, err := parseIPNets(strings.Split(val, ","))
+		if err != nil {
+			return err
+		}
+		cfg.limiter.exemptNets = nets
+		return nil
+	})
+	cfg.limiter.exemptPaths = []string{"/v1/healthcheck"}
+	flag.Func("limiter-exempt-paths", "Rate limiter exempt paths (comma separated)", func(val string) error {
+		cfg.limiter.exemptPaths = parsePaths(strings.Split(val, ","))
+		return nil
+	})
+	// Routes which need a tighter (or looser) limit than the global default can be
+	// configured by repeating the limiter-route flag. The first matching route wins.
+	flag.Func("limiter-route", `Per-route rate limit as "[METHOD] PATH RPS BURST" (repeatable)`, func(val string) error {
+		rl, err := parseRouteLimit(val)
+		if err != nil {
+			return err
+		}
+		cfg.limiter.routes = append(cfg.limiter.routes, rl)
+		return nil
+	})
+	// Only compress responses which are big enough to be worth it, and whose content
+	// type is in the allowlist. Entries ending in "/*" match any subtype.
+	flag.BoolVar(&cfg.compression.enabled, "compression-enabled", true, "Enable gzip response compression")
+	flag.IntVar(&cfg.compression.minSize, "compression-min-size", 1024, "Minimum response size in bytes to compress")
+	cfg.compression.contentTypes = []string{"application/json", "text/*"}
+	flag.Func("compression-types", "Compressible content types (comma separated)", func(val string) error {
+		cfg.compression.contentTypes = strings.Split(val, ",")
+		return nil
+	})
+	flag.BoolVar(&cfg.products.uniqueTitlePerOwner, "products-unique-title-per-owner", false, "Reject duplicate product titles from the same owner")
+	cfg.views.flushInterval = 30 * time.Second
+	flag.Func("views-flush-interval", "Interval between product view count flushes (default 30s)", func(val string) error {
+		d, err := time.ParseDuration(val)
+		if err != nil || d <= 0 {
+			return errors.New("must be a positive duration")
+		}
+		cfg.views.flushInterval = d
+		return nil
+	})
+	flag.Func("json-timestamp-format", "Timestamp format in JSON responses (rfc3339|rfc3339nano|unix)", data.SetTimestampFormat)
+	cfg.feed.items = 20
+	flag.Func("feed-items", "Number of products in the new arrivals feed (1-100, default 20)", func(val string) error {
+		n, err := strconv.Atoi(val)
+		if err != nil || n < 1 || n > 100 {
+			return errors.New("must be a number between 1 and 100")
+		}
+		cfg.feed.items = n
+		return nil
+	})
 	// Read the SMTP server configuration settings into the config struct, using the
 	// Mailtrap settings as the default values. IMPORTANT: If you're following along,
 	// make sure to replace the default values for smtp-username and smtp-password
@@ -109,7 +185,7 @@ func main() {
 	app := &application{
 		config: cfg,
 		logger: logger,
-		models: data.NewModels(db),
+		models: data.NewModels(db, cfg.products.uniqueTitlePerOwner),
 		mailer: mailer.New(cfg.smtp.host, cfg.smtp.port, cfg.smtp.username, cfg.smtp.password, cfg.smtp.sender),
 	}
 
@@ -1,10 +1,14 @@
 package main
 
 import (
+	"compress/gzip"
 	"fmt"
 	"golang.org/x/time/rate"
+	"mime"
 	"net"
 	"net/http"
+	"strconv"
+	"strings"
 	"sync"
 	"time"
 )
@@ -72,16 +76,29 @@ func (app *application) rateLimit(next http.Handler) http.Handler {
 				app.serverErrorResponse(w, r, err)
 				return
 			}
+			// Requests from exempt networks or for exempt paths skip the limiter
+			// entirely.
+			if app.rateLimitExempt(net.ParseIP(ip), r.URL.Path) {
+				next.ServeHTTP(w, r)
+				return
+			}
+			// Clients are tracked separately for each route limit, so that hitting a
+			// tight limit on one route doesn't use up the default allowance for the
+			// rest of the API.
+			key := ip
+			rps, burst := app.config.limiter.rps, app.config.limiter.burst
+			if route := app.matchRouteLimit(r); route != nil {
+				key = ip + " " + route.method + " " + route.pattern
+				rps, burst = route.rps, route.burst
+			}
 			mu.Lock()
-			if _, found := clients[ip]; !found {
-				clients[ip] = &client{
-					// Use the requests-per-second and burst values from the config
-					// struct.
-					limiter: rate.NewLimiter(rate.Limit(app.config.limiter.rps), app.config.limiter.burst),
+			if _, found := clients[key]; !found {
+				clients[key] = &client{
+					limiter: rate.NewLimiter(rate.Limit(rps), burst),
 				}
 			}
-			clients[ip].lastSeen = time.Now()
-			if !clients[ip].limiter.Allow() {
+			clients[key].lastSeen = time.Now()
+			if !clients[key].limiter.Allow() {
 				mu.Unlock()
 				app.rateLimitExceededResponse(w, r)
 				return
@@ -91,3 +108,272 @@ func (app *application) rateLimit(next http.Handler) http.Handler {
 		next.ServeHTTP(w, r)
 	})
 }
+
+// The routeLimit type holds a rate limit which applies to requests matching a method
+// and path pattern instead of the global default. An empty method matches any method,
+// and a pattern ending in "*" matches any path with that prefix.
+type routeLimit struct {
+	method  string
+	pattern string
+	rps     float64
+	burst   int
+}
+
+func (rl routeLimit) matches(r *http.Request) bool {
+	if rl.method != "" && rl.method != r.Method {
+		return false
+	}
+	if strings.HasSuffix(rl.pattern, "*") {
+		return strings.HasPrefix(r.URL.Path, strings.TrimSuffix(rl.pattern, "*"))
+	}
+	return r.URL.Path == rl.pattern
+}
+
+// The matchRouteLimit() method returns the first configured route limit which matches
+// the request, or nil if the global limit applies.
+func (app *application) matchRouteLimit(r *http.Request) *routeLimit {
+	for i := range app.config.limiter.routes {
+		if app.config.limiter.routes[i].matches(r) {
+			return &app.config.limiter.routes[i]
+		}
+	}
+	return nil
+}
+
+// The parseRouteLimit() helper parses a route limit in the format
+// "[METHOD] PATTERN RPS BURST", for example "POST /v1/users/orders 0.5 2".
+func parseRouteLimit(value string) (routeLimit, error) {
+	var rl routeLimit
+	fields := strings.Fields(value)
+	switch len(fields) {
+	case 3:
+	case 4:
+		rl.method = strings.ToUpper(fields[0])
+		fields = fields[1:]
+	default:
+		return rl, fmt.Errorf("invalid route limit %q", value)
+	}
+	rl.pattern = fields[0]
+	rps, err := strconv.ParseFloat(fields[1], 64)
+	if err != nil || rps <= 0 {
+		return rl, fmt.Errorf("invalid requests per second in route limit %q", value)
+	}
+	burst, err := strconv.Atoi(fields[2])
+	if err != nil || burst < 1 {
+		return rl, fmt.Errorf("invalid burst in route limit %q", value)
+	}
+	rl.rps = rps
+	rl.burst = burst
+	return rl, nil
+}
+
+// The rateLimitExempt() method reports whether a request from the given client IP for
+// the given path should bypass the rate limiter.
+func (app *application) rateLimitExempt(ip net.IP, path string) bool {
+	for _, exemptPath := range app.config.limiter.exemptPaths {
+		if path == exemptPath {
+			return true
+		}
+	}
+	if ip == nil {
+		return false
+	}
+	for _, exemptNet := range app.config.limiter.exemptNets {
+		if exemptNet.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// The parseIPNets() helper converts a list of IP addresses and CIDR blocks into
+// *net.IPNet values. A bare IP address is treated as a single-host network. Empty
+// entries are ignored, and an error is returned for the first value that can't be
+// parsed.
+func parseIPNets(values []string) ([]*net.IPNet, error) {
+	var nets []*net.IPNet
+	for _, value := range values {
+		value = strings.TrimSpace(value)
+		if value == "" {
+			continue
+		}
+		if !strings.Contains(value, "/") {
+			ip := net.ParseIP(value)
+			if ip == nil {
+				return nil, fmt.Errorf("invalid IP address %q", value)
+			}
+			bits := 8 * net.IPv6len
+			if ip.To4() != nil {
+				ip = ip.To4()
+				bits = 8 * net.IPv4len
+			}
+			nets = append(nets, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+		_, ipNet, err := net.ParseCIDR(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid CIDR %q", value)
+		}
+		nets = append(nets, ipNet)
+	}
+	return nets, nil
+}
+
+// The parsePaths() helper trims the whitespace around each request path in a list
+// and drops empty entries, so that "/v1/healthcheck, /debug/vars," exempts both paths.
+func parsePaths(values []string) []string {
+	var paths []string
+	for _, value := range values {
+		value = strings.TrimSpace(value)
+		if value == "" {
+			continue
+		}
+		paths = append(paths, value)
+	}
+	return paths
+}
+
+// The compress() middleware gzips responses for clients which accept it. To avoid
+// wasting CPU, a response is only compressed once it reaches the configured minimum
+// size and if its content type is in the configured allowlist. Responses which are
+// flushed before reaching the minimum size (i.e. streamed responses) are never
+// compressed.
+func (app *application) compress(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !app.config.compression.enabled {
+			next.ServeHTTP(w, r)
+			return
+		}
+		w.Header().Add("Vary", "Accept-Encoding")
+		if !acceptsGzip(r.Header.Get("Accept-Encoding")) {
+			next.ServeHTTP(w, r)
+			return
+		}
+		cw := &compressWriter{
+			ResponseWriter: w,
+			minSize:        app.config.compression.minSize,
+			contentTypes:   app.config.compression.contentTypes,
+			status:         http.StatusOK,
+		}
+		defer cw.close()
+		next.ServeHTTP(cw, r)
+	})
+}
+
+// The compressWriter type buffers the start of a response until it knows whether the
+// response should be compressed: either the buffer reaches minSize, or the handler
+// flushes or finishes first.
+type compressWriter struct {
+	http.ResponseWriter
+	minSize      int
+	contentTypes []string
+	status       int
+	buf          []byte
+	decided      bool
+	gz           *gzip.Writer
+}
+
+func (cw *compressWriter) WriteHeader(status int) {
+	if !cw.decided {
+		cw.status = status
+	}
+}
+
+func (cw *compressWriter) Write(b []byte) (int, error) {
+	if cw.decided {
+		if cw.gz != nil {
+			return cw.gz.Write(b)
+		}
+		return cw.ResponseWriter.Write(b)
+	}
+	cw.buf = append(cw.buf, b...)
+	if len(cw.buf) >= cw.minSize {
+		if err := cw.decide(true); err != nil {
+			return 0, err
+		}
+	}
+	return len(b), nil
+}
+
+// Flush sends anything buffered so far to the client. A response which is flushed
+// before it has been compressed is treated as a stream and left uncompressed.
+func (cw *compressWriter) Flush() {
+	if !cw.decided {
+		cw.decide(false)
+	}
+	if cw.gz != nil {
+		cw.gz.Flush()
+	}
+	if flusher, ok := cw.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+// The decide() method writes the response headers and the buffered data, compressing
+// them if the response is big enough and has a compressible content type.
+func (cw *compressWriter) decide(bigEnough bool) error {
+	cw.decided = true
+	h := cw.Header()
+	if bigEnough && h.Get("Content-Encoding") == "" && compressible(h.Get("Content-Type"), cw.contentTypes) {
+		h.Set("Content-Encoding", "gzip")
+		h.Del("Content-Length")
+		cw.ResponseWriter.WriteHeader(cw.status)
+		cw.gz = gzip.NewWriter(cw.ResponseWriter)
+		_, err := cw.gz.Write(cw.buf)
+		cw.buf = nil
+		return err
+	}
+	cw.ResponseWriter.WriteHeader(cw.status)
+	_, err := cw.ResponseWriter.Write(cw.buf)
+	cw.buf = nil
+	return err
+}
+
+func (cw *compressWriter) close() {
+	if !cw.decided {
+		cw.decide(false)
+	}
+	if cw.gz != nil {
+		cw.gz.Close()
+	}
+}
+
+// The acceptsGzip() helper reports whether an Accept-Encoding header value allows a
+// gzip-encoded response.
+func acceptsGzip(acceptEncoding string) bool {
+	for _, part := range strings.Split(acceptEncoding, ",") {
+		coding, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		if strings.TrimSpace(coding) != "gzip" {
+			continue
+		}
+		params = strings.ReplaceAll(params, " ", "")
+		if strings.HasPrefix(params, "q=") {
+			q, err := strconv.ParseFloat(strings.TrimPrefix(params, "q="), 64)
+			return err == nil && q > 0
+		}
+		return true
+	}
+	return false
+}
+
+// The compressible() helper reports whether a Content-Type header value matches one
+// of the allowed content types. Allowed types ending in "/*" match any subtype.
+func compressible(contentType string, allowed []string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+	for _, allowedType := range allowed {
+		allowedType = strings.TrimSpace(allowedType)
+		if strings.HasSuffix(allowedType, "/*") {
+			if strings.HasPrefix(mediaType, strings.TrimSuffix(allowedType, "*")) {
+				return true
+			}
+			continue
+		}
+		if mediaType == allowedType {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,207 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+var okHandler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+	w.Write([]byte("OK"))
+})
+
+// The statuses() helper sends n identical requests and returns their status codes.
+func statuses(t *testing.T, h http.Handler, n int, method, path, remoteAddr string) []int {
+	codes := make([]int, n)
+	for i := range codes {
+		codes[i] = do(t, h, method, path, remoteAddr).Code
+	}
+	return codes
+}
+
+func TestRateLimitExemptions(t *testing.T) {
+	app := newTestApplication(t)
+	app.config.limiter.enabled = true
+	app.config.limiter.rps = 1
+	app.config.limiter.burst = 2
+	nets, err := parseIPNets([]string{"10.0.0.0/8", "192.168.1.5"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	app.config.limiter.exemptNets = nets
+	app.config.limiter.exemptPaths = parsePaths(strings.Split(" /v1/healthcheck, ,/debug/vars ,", ","))
+	h := app.rateLimit(okHandler)
+
+	tests := []struct {
+		name       string
+		path       string
+		remoteAddr string
+		throttled  bool
+	}{
+		{"Exempt network", "/v1/movies", "10.1.2.3:1234", false},
+		{"Exempt host", "/v1/movies", "192.168.1.5:1234", false},
+		{"Exempt path", "/v1/healthcheck", "203.0.113.1:1234", false},
+		{"Exempt path with spaces", "/debug/vars", "203.0.113.3:1234", false},
+		{"Empty path entry", "/", "203.0.113.4:1234", true},
+		{"Not exempt", "/v1/movies", "203.0.113.2:1234", true},
+		{"Outside exempt host", "/v1/movies", "192.168.1.6:1234", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			codes := statuses(t, h, 5, http.MethodGet, tt.path, tt.remoteAddr)
+			got := codes[len(codes)-1] == http.StatusTooManyRequests
+			if got != tt.throttled {
+				t.Errorf("got statuses %v; want throttled %t", codes, tt.throttled)
+			}
+		})
+	}
+}
+
+func TestParseIPNets(t *testing.T) {
+	nets, err := parseIPNets([]string{"127.0.0.1", " ", "::1", "172.16.0.0/12"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(nets) != 3 {
+		t.Fatalf("got %d networks; want 3", len(nets))
+	}
+	for _, value := range []string{"not-an-ip", "10.0.0.0/33"} {
+		if _, err := parseIPNets([]string{value}); err == nil {
+			t.Errorf("parseIPNets(%q): got nil error", value)
+		}
+	}
+}
+
+func TestRateLimitPerRoute(t *testing.T) {
+	app := newTestApplication(t)
+	app.config.limiter.enabled = true
+	app.config.limiter.rps = 100
+	app.config.limiter.burst = 100
+	rl, err := parseRouteLimit("POST /v1/users/orders 0.1 1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	app.config.limiter.routes = []routeLimit{rl}
+	h := app.rateLimit(okHandler)
+	const addr = "203.0.113.1:1234"
+
+	codes := statuses(t, h, 2, http.MethodPost, "/v1/users/orders", addr)
+	if codes[0] != http.StatusOK || codes[1] != http.StatusTooManyRequests {
+		t.Errorf("orders: got statuses %v; want [200 429]", codes)
+	}
+	// Reads from the same client still get the default allowance.
+	for _, code := range statuses(t, h, 10, http.MethodGet, "/v1/movies", addr) {
+		if code != http.StatusOK {
+			t.Fatalf("reads: got status %d; want 200", code)
+		}
+	}
+	// The route limit only applies to the configured method.
+	if code := do(t, h, http.MethodGet, "/v1/users/orders", addr).Code; code != http.StatusOK {
+		t.Errorf("GET orders: got status %d; want 200", code)
+	}
+}
+
+func TestParseRouteLimit(t *testing.T) {
+	tests := []struct {
+		value string
+		want  routeLimit
+		err   bool
+	}{
+		{"POST /v1/users/orders 0.5 2", routeLimit{"POST", "/v1/users/orders", 0.5, 2}, false},
+		{"/v1/movies* 10 20", routeLimit{"", "/v1/movies*", 10, 20}, false},
+		{"get /v1/movies 1 1", routeLimit{"GET", "/v1/movies", 1, 1}, false},
+		{"/v1/movies 1", routeLimit{}, true},
+		{"/v1/movies 0 1", routeLimit{}, true},
+		{"/v1/movies 1 0", routeLimit{}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			got, err := parseRouteLimit(tt.value)
+			if tt.err {
+				if err == nil {
+					t.Errorf("got nil error; want error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("got %+v; want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRouteLimitMatches(t *testing.T) {
+	prefix := routeLimit{pattern: "/v1/movies*"}
+	for path, want := range map[string]bool{"/v1/movies": true, "/v1/movies/5": true, "/v1/users": false} {
+		r, _ := http.NewRequest(http.MethodGet, path, nil)
+		if got := prefix.matches(r); got != want {
+			t.Errorf("%s: got %t; want %t", path, got, want)
+		}
+	}
+}
+
+func TestCompress(t *testing.T) {
+	app := newTestApplication(t)
+	app.config.compression.enabled = true
+	app.config.compression.minSize = 1024
+	app.config.compression.contentTypes = []string{"application/json", "text/*"}
+
+	large := bytes.Repeat([]byte("a"), 2048)
+	small := []byte(`{"ok":true}`)
+	tests := []struct {
+		name           string
+		contentType    string
+		body           []byte
+		flush          bool
+		acceptEncoding string
+		compressed     bool
+	}{
+		{"Large JSON", "application/json", large, false, "gzip", true},
+		{"Large text", "text/plain; charset=utf-8", large, false, "gzip, deflate", true},
+		{"Small JSON", "application/json", small, false, "gzip", false},
+		{"Image", "image/png", large, false, "gzip", false},
+		{"Streamed", "application/json", large, true, "gzip", false},
+		{"Not accepted", "application/json", large, false, "", false},
+		{"Refused", "application/json", large, false, "gzip;q=0", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", tt.contentType)
+				if tt.flush {
+					w.(http.Flusher).Flush()
+				}
+				w.Write(tt.body)
+			})
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+			r.Header.Set("Accept-Encoding", tt.acceptEncoding)
+			rr := httptest.NewRecorder()
+			app.compress(next).ServeHTTP(rr, r)
+
+			compressed := rr.Header().Get("Content-Encoding") == "gzip"
+			if compressed != tt.compressed {
+				t.Fatalf("got compressed %t; want %t", compressed, tt.compressed)
+			}
+			body := rr.Body.Bytes()
+			if compressed {
+				zr, err := gzip.NewReader(rr.Body)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if body, err = io.ReadAll(zr); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if !bytes.Equal(body, tt.body) {
+				t.Errorf("got body %q; want %q", body, tt.body)
+			}
+		})
+	}
+}
@@ -1,24 +1,33 @@
 package main
 
 import (
+	"encoding/xml"
 	"errors"
 	"finalproject/internal/data"
+	"finalproject/internal/validator"
 	"net/http"
+	"time"
 )
 
 // Add a showMovieHandler for the "GET /v1/movies/:id" endpoint. For now, we retrieve
 // the interpolated "id" parameter from the current URL and include it in a placeholder
 // response.
 func (app *application) showProductHandler(w http.ResponseWriter, r *http.Request) {
-	id, err := app.readIDParam(r)
+	id, uuid, err := app.readIDOrUUIDParam(r)
 	if err != nil {
 		app.notFoundResponse(w, r)
 		return
 	}
-	// Call the Get() method to fetch the data for a specific movie. We also need to
-	// use the errors.Is() function to check if it returns a data.ErrRecordNotFound
-	// error, in which case we send a 404 Not Found response to the client.
-	movie, err := app.models.Products.Get(id, r)
+	// Call the Get() (or GetByUUID()) method to fetch the data for a specific movie. We
+	// also need to use the errors.Is() function to check if it returns a
+	// data.ErrRecordNotFound error, in which case we send a 404 Not Found response to
+	// the client.
+	var movie *data.Product
+	if uuid != "" {
+		movie, err = app.models.Products.GetByUUID(uuid, r)
+	} else {
+		movie, err = app.models.Products.Get(id, r)
+	}
 	if err != nil {
 		switch {
 		case errors.Is(err, data.ErrRecordNotFound):
@@ -28,6 +37,7 @@ func (app *application) showProductHandler(w http.ResponseWriter, r *http.Reques
 		}
 		return
 	}
+	app.models.Views.Increment(movie.ID)
 	err = app.writeJSON(w, http.StatusOK, envelope{"movie": movie}, nil)
 	if err != nil {
 		app.serverErrorResponse(w, r, err)
@@ -47,6 +57,139 @@ func (app *application) updateProductHandler(w http.ResponseWriter, r *http.Requ
 func (app *application) deleteProductHandler(w http.ResponseWriter, r *http.Request) {
 
 }
+
+// The appliedFilters type echoes back the filters which produced a list response, so
+// that clients can render "showing results for...". Filters which weren't set are
+// omitted.
+type appliedFilters struct {
+	Title          string            `json:"title,omitempty"`
+	Categories     []string          `json:"categories,omitempty"`
+	Tags           []string          `json:"tags,omitempty"`
+	Specifications map[string]string `json:"specifications,omitempty"`
+	Sort           string            `json:"sort,omitempty"`
+}
+
 func (app *application) listProductsHandler(w http.ResponseWriter, r *http.Request) {
+	// To keep things consistent with our other handlers, we'll define an input struct
+	// to hold the expected values from the request query string.
+	var input struct {
+		Title          string
+		Categories     []string
+		Tags           []string
+		Specifications map[string]string
+		data.Filters
+	}
+	v := validator.New()
+	qs := r.URL.Query()
+	input.Title = app.readString(qs, "title", "")
+	input.Categories = app.readCSV(qs, "categories", []string{})
+	input.Tags = app.readCSV(qs, "tags", []string{})
+	// Specification filters are passed as spec.<key>=<value>, e.g. spec.RAM=8GB.
+	input.Specifications = app.readPrefixed(qs, "spec.")
+	input.Filters.Page = app.readInt(qs, "page", 1, v)
+	input.Filters.PageSize = app.readInt(qs, "page_size", 20, v)
+	input.Filters.SortSafelist = data.SortSafelists["products"]
+	// Clients paging through a large catalog can pass the next_cursor from the previous
+	// response as "after" instead of a page number.
+	input.Filters.Cursor = app.readString(qs, "after", "")
+	// Searches are sorted with the best matches first unless the client chose a sort.
+	// Cursors need an id sort, so they keep the id default.
+	defaultSort := "id"
+	if input.Title != "" && input.Filters.Cursor == "" {
+		defaultSort = "relevance"
+	}
+	input.Filters.Sort = app.readString(qs, "sort", defaultSort)
+	if data.ValidateFilters(v, input.Filters); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+	products, metadata, err := app.models.Products.GetAll(input.Title, input.Categories, input.Tags, input.Specifications, input.Filters, r)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+	metadata.SetPageLinks(r.URL)
+	env := envelope{"products": products, "metadata": metadata}
+	applied := appliedFilters{
+		Title:          input.Title,
+		Categories:     input.Categories,
+		Tags:           input.Tags,
+		Specifications: input.Specifications,
+		Sort:           app.readString(qs, "sort", ""),
+	}
+	if applied.Title != "" || len(applied.Categories) > 0 || len(applied.Tags) > 0 || len(applied.Specifications) > 0 || applied.Sort != "" {
+		env["applied_filters"] = applied
+	}
+	err = app.writeJSON(w, http.StatusOK, env, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// The rssFeed and rssItem types describe the RSS 2.0 document served by
+// productFeedHandler.
+type rssFeed struct {
+	XMLName xml.Name `xml:"rss"`
+	Version string   `xml:"version,attr"`
+	Channel struct {
+		Title       string    `xml:"title"`
+		Link        string    `xml:"link"`
+		Description string    `xml:"description"`
+		Items       []rssItem `xml:"item"`
+	} `xml:"channel"`
+}
+
+type rssItem struct {
+	Title       string `xml:"title"`
+	Link        string `xml:"link"`
+	Description string `xml:"description"`
+	GUID        string `xml:"guid"`
+	PubDate     string `xml:"pubDate"`
+}
 
+// The productFeedHandler serves an RSS feed of the most recently created products for
+// integrators which can't consume the JSON API. The number of items is set with the
+// -feed-items flag. The feed goes through the rate limiter like every other route.
+func (app *application) productFeedHandler(w http.ResponseWriter, r *http.Request) {
+	filters := data.Filters{
+		Page:         1,
+		PageSize:     app.config.feed.items,
+		Sort:         "-created_at",
+		SortSafelist: data.SortSafelists["products"],
+	}
+	products, _, err := app.models.Products.GetAll("", []string{}, []string{}, nil, filters, r)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+	scheme := "http"
+	if r.TLS != nil {
+		scheme = "https"
+	}
+	baseURL := scheme + "://" + r.Host
+	var feed rssFeed
+	feed.Version = "2.0"
+	feed.Channel.Title = "New arrivals"
+	feed.Channel.Link = baseURL + "/v1/movies"
+	feed.Channel.Description = "The most recently added products"
+	for _, product := range products {
+		link := baseURL + "/v1/movies/" + product.UUID
+		feed.Channel.Items = append(feed.Channel.Items, rssItem{
+			Title:       product.Title,
+			Link:        link,
+			Description: product.Description,
+			GUID:        link,
+			PubDate:     product.CreatedAt.Format(time.RFC1123Z),
+		})
+	}
+	body, err := xml.MarshalIndent(feed, "", "\t")
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/rss+xml; charset=utf-8")
+	w.Header().Set("Cache-Control", "public, max-age=300")
+	w.WriteHeader(http.StatusOK)
+	w.Write([]byte(xml.Header))
+	w.Write(body)
 }
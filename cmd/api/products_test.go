@@ -0,0 +1,220 @@
+package main
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"finalproject/internal/data"
+	"net/http"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestListProductsSpecificationFilter(t *testing.T) {
+	app := newTestApplication(t)
+	var calls []getAllCall
+	app.models.Products = stubProducts{calls: &calls}
+
+	rr := do(t, http.HandlerFunc(app.listProductsHandler), http.MethodGet, "/v1/movies?spec.RAM=8GB&spec.Color=Space+Gray", "")
+	if rr.Code != http.StatusOK {
+		t.Fatalf("got status %d; want 200", rr.Code)
+	}
+	want := map[string]string{"RAM": "8GB", "Color": "Space Gray"}
+	if len(calls) != 1 || !reflect.DeepEqual(calls[0].specs, want) {
+		t.Errorf("got GetAll calls %+v; want specs %v", calls, want)
+	}
+}
+
+func TestListProductsTagFilter(t *testing.T) {
+	app := newTestApplication(t)
+	var calls []getAllCall
+	app.models.Products = stubProducts{calls: &calls}
+
+	rr := do(t, http.HandlerFunc(app.listProductsHandler), http.MethodGet, "/v1/movies?tags=summer,vegan", "")
+	if rr.Code != http.StatusOK {
+		t.Fatalf("got status %d; want 200", rr.Code)
+	}
+	want := []string{"summer", "vegan"}
+	if len(calls) != 1 || !reflect.DeepEqual(calls[0].tags, want) {
+		t.Errorf("got GetAll calls %+v; want tags %v", calls, want)
+	}
+}
+
+func TestShowProduct(t *testing.T) {
+	app := newTestApplication(t)
+	app.models.Products = stubProducts{products: []*data.Product{
+		{ID: 1, UUID: "0b6c3a52-7f1e-4f4e-9a43-3c1f0e6d2a10", Title: "Laptop"},
+	}}
+	h := app.routes()
+
+	tests := []struct {
+		name   string
+		path   string
+		status int
+	}{
+		{"Internal id", "/v1/movies/1", http.StatusOK},
+		{"UUID", "/v1/movies/0b6c3a52-7f1e-4f4e-9a43-3c1f0e6d2a10", http.StatusOK},
+		{"Upper case UUID", "/v1/movies/0B6C3A52-7F1E-4F4E-9A43-3C1F0E6D2A10", http.StatusOK},
+		{"Unknown id", "/v1/movies/2", http.StatusNotFound},
+		{"Unknown UUID", "/v1/movies/11111111-2222-3333-4444-555555555555", http.StatusNotFound},
+		{"Negative id", "/v1/movies/-1", http.StatusNotFound},
+		{"Malformed", "/v1/movies/laptop", http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rr := do(t, h, http.MethodGet, tt.path, "")
+			if rr.Code != tt.status {
+				t.Fatalf("got status %d; want %d", rr.Code, tt.status)
+			}
+			if tt.status != http.StatusOK {
+				return
+			}
+			var body struct {
+				Movie data.Product `json:"movie"`
+			}
+			if err := json.NewDecoder(rr.Body).Decode(&body); err != nil {
+				t.Fatal(err)
+			}
+			if body.Movie.ID != 1 {
+				t.Errorf("got product %d; want 1", body.Movie.ID)
+			}
+		})
+	}
+}
+
+func TestListProductsAppliedFilters(t *testing.T) {
+	app := newTestApplication(t)
+	app.models.Products = stubProducts{}
+	h := http.HandlerFunc(app.listProductsHandler)
+
+	t.Run("Echoed", func(t *testing.T) {
+		rr := do(t, h, http.MethodGet, "/v1/movies?title=laptop&categories=electronics,computers&tags=sale&spec.RAM=8GB&sort=-title", "")
+		if rr.Code != http.StatusOK {
+			t.Fatalf("got status %d; want 200", rr.Code)
+		}
+		var body struct {
+			AppliedFilters appliedFilters `json:"applied_filters"`
+		}
+		if err := json.NewDecoder(rr.Body).Decode(&body); err != nil {
+			t.Fatal(err)
+		}
+		want := appliedFilters{
+			Title:          "laptop",
+			Categories:     []string{"electronics", "computers"},
+			Tags:           []string{"sale"},
+			Specifications: map[string]string{"RAM": "8GB"},
+			Sort:           "-title",
+		}
+		if !reflect.DeepEqual(body.AppliedFilters, want) {
+			t.Errorf("got %+v; want %+v", body.AppliedFilters, want)
+		}
+	})
+
+	t.Run("Omitted", func(t *testing.T) {
+		rr := do(t, h, http.MethodGet, "/v1/movies?page=2", "")
+		var body map[string]json.RawMessage
+		if err := json.NewDecoder(rr.Body).Decode(&body); err != nil {
+			t.Fatal(err)
+		}
+		if _, ok := body["applied_filters"]; ok {
+			t.Errorf("got applied_filters %s; want it omitted", body["applied_filters"])
+		}
+	})
+}
+
+func TestListProductsDefaultSort(t *testing.T) {
+	tests := []struct {
+		name  string
+		query string
+		want  string
+	}{
+		{"No search", "", "id"},
+		{"Search", "?title=waterproof", "relevance"},
+		{"Search with explicit sort", "?title=waterproof&sort=-created_at", "-created_at"},
+		{"Search with cursor", "?title=waterproof&after=MTA", "id"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app := newTestApplication(t)
+			var calls []getAllCall
+			app.models.Products = stubProducts{calls: &calls}
+			rr := do(t, http.HandlerFunc(app.listProductsHandler), http.MethodGet, "/v1/movies"+tt.query, "")
+			if rr.Code != http.StatusOK {
+				t.Fatalf("got status %d; want 200 (body: %s)", rr.Code, rr.Body)
+			}
+			if got := calls[0].filters.Sort; got != tt.want {
+				t.Errorf("got sort %q; want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestListProductsCursor(t *testing.T) {
+	app := newTestApplication(t)
+	var calls []getAllCall
+	app.models.Products = stubProducts{calls: &calls}
+	h := http.HandlerFunc(app.listProductsHandler)
+
+	rr := do(t, h, http.MethodGet, "/v1/movies?after=MTA", "")
+	if rr.Code != http.StatusOK {
+		t.Fatalf("got status %d; want 200 (body: %s)", rr.Code, rr.Body)
+	}
+	if got := calls[0].filters.Cursor; got != "MTA" {
+		t.Errorf("got cursor %q; want \"MTA\"", got)
+	}
+	for _, query := range []string{"?after=garbage", "?after=MTA&sort=title"} {
+		if rr := do(t, h, http.MethodGet, "/v1/movies"+query, ""); rr.Code != http.StatusUnprocessableEntity {
+			t.Errorf("%s: got status %d; want 422", query, rr.Code)
+		}
+	}
+}
+
+func TestProductFeed(t *testing.T) {
+	app := newTestApplication(t)
+	app.config.feed.items = 20
+	var calls []getAllCall
+	app.models.Products = stubProducts{
+		calls: &calls,
+		products: []*data.Product{
+			{ID: 2, UUID: "0b6c3a52-7f1e-4f4e-9a43-3c1f0e6d2a10", Title: "Rain jacket", Description: "Waterproof & light",
+				CreatedAt: time.Date(2023, 5, 2, 9, 0, 0, 0, time.UTC)},
+			{ID: 1, UUID: "8d1f0c3e-2b4a-4c6d-9e8f-7a6b5c4d3e2f", Title: "Boots <winter>",
+				CreatedAt: time.Date(2023, 5, 1, 9, 0, 0, 0, time.UTC)},
+		},
+	}
+	rr := do(t, app.routes(), http.MethodGet, "/v1/products/feed.xml", "")
+	if rr.Code != http.StatusOK {
+		t.Fatalf("got status %d; want 200", rr.Code)
+	}
+	if got := rr.Header().Get("Content-Type"); got != "application/rss+xml; charset=utf-8" {
+		t.Errorf("got Content-Type %q", got)
+	}
+	if got := rr.Header().Get("Cache-Control"); got == "" {
+		t.Error("missing Cache-Control header")
+	}
+	if len(calls) != 1 || calls[0].filters.Sort != "-created_at" || calls[0].filters.PageSize != 20 {
+		t.Errorf("got GetAll calls %+v; want the 20 newest products", calls)
+	}
+
+	var feed rssFeed
+	if err := xml.Unmarshal(rr.Body.Bytes(), &feed); err != nil {
+		t.Fatalf("invalid XML: %v\n%s", err, rr.Body)
+	}
+	items := feed.Channel.Items
+	if len(items) != 2 {
+		t.Fatalf("got %d items; want 2", len(items))
+	}
+	want := rssItem{
+		Title:       "Rain jacket",
+		Link:        "http://example.com/v1/movies/0b6c3a52-7f1e-4f4e-9a43-3c1f0e6d2a10",
+		Description: "Waterproof & light",
+		GUID:        "http://example.com/v1/movies/0b6c3a52-7f1e-4f4e-9a43-3c1f0e6d2a10",
+		PubDate:     "Tue, 02 May 2023 09:00:00 +0000",
+	}
+	if items[0] != want {
+		t.Errorf("got item %+v; want %+v", items[0], want)
+	}
+	if items[1].Title != "Boots <winter>" {
+		t.Errorf("got title %q; want \"Boots <winter>\"", items[1].Title)
+	}
+}
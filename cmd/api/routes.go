@@ -10,14 +10,18 @@ func (app *application) routes() http.Handler {
 	router.NotFound = http.HandlerFunc(app.notFoundResponse)
 	router.MethodNotAllowed = http.HandlerFunc(app.methodNotAllowedResponse)
 	router.HandlerFunc(http.MethodGet, "/v1/healthcheck", app.healthcheckHandler)
+	router.HandlerFunc(http.MethodGet, "/debug/vars", app.debugVarsHandler)
 	router.HandlerFunc(http.MethodGet, "/v1/movies", app.listProductsHandler)
 	router.HandlerFunc(http.MethodPost, "/v1/movies", app.createProductHandler)
 	router.HandlerFunc(http.MethodGet, "/v1/movies/:id", app.showProductHandler)
 	router.HandlerFunc(http.MethodPatch, "/v1/movies/:id", app.updateProductHandler)
 	router.HandlerFunc(http.MethodDelete, "/v1/movies/:id", app.deleteProductHandler)
+	// The feed can't live under /v1/movies, as httprouter doesn't allow a static
+	// segment alongside the :id parameter.
+	router.HandlerFunc(http.MethodGet, "/v1/products/feed.xml", app.productFeedHandler)
 	router.HandlerFunc(http.MethodPost, "/v1/users", app.registerUserHandler)
 	// Add the route for the PUT /v1/users/activated endpoint.
 	router.HandlerFunc(http.MethodPut, "/v1/users/activated", app.activateUserHandler)
-	return app.recoverPanic(app.rateLimit(router))
+	return app.compress(app.recoverPanic(app.rateLimit(router)))
 
 }
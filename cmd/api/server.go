@@ -20,6 +20,21 @@ func (app *application) serve() error {
 		WriteTimeout: 30 * time.Second,
 	}
 	shutdownError := make(chan error)
+	// Periodically write the buffered product view counts to the database until the
+	// server starts shutting down.
+	stopFlushing := make(chan struct{})
+	go func() {
+		ticker := time.NewTicker(app.config.views.flushInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				app.flushViews()
+			case <-stopFlushing:
+				return
+			}
+		}
+	}()
 	go func() {
 		quit := make(chan os.Signal, 1)
 		signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
@@ -29,23 +44,7 @@ func (app *application) serve() error {
 		})
 		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 		defer cancel()
-		// Call Shutdown() on the server like before, but now we only send on the
-		// shutdownError channel if it returns an error.
-		err := srv.Shutdown(ctx)
-		if err != nil {
-			shutdownError <- err
-		}
-		// Log a message to say that we're waiting for any background goroutines to
-		// complete their tasks.
-		app.logger.PrintInfo("completing background tasks", map[string]string{
-			"addr": srv.Addr,
-		})
-		// Call Wait() to block until our WaitGroup counter is zero --- essentially
-		// blocking until the background goroutines have finished. Then we return nil on
-		// the shutdownError channel, to indicate that the shutdown completed without
-		// any issues.
-		app.wg.Wait()
-		shutdownError <- nil
+		shutdownError <- app.shutdown(ctx, srv, stopFlushing)
 	}()
 	app.logger.PrintInfo("starting server", map[string]string{
 		"addr": srv.Addr,
@@ -71,3 +70,35 @@ func (app *application) serve() error {
 	})
 	return nil
 }
+
+// The shutdown() method gracefully shuts down the server, waits for any background
+// goroutines to complete, and then stops the periodic flushes and writes any remaining
+// view counts. This happens before serve() returns, so before main() closes the
+// database connection pool. The final flush happens even if Shutdown() fails (e.g.
+// because in-flight requests outlasted ctx), so that buffered counts aren't lost.
+func (app *application) shutdown(ctx context.Context, srv *http.Server, stopFlushing chan struct{}) error {
+	err := srv.Shutdown(ctx)
+	if err == nil {
+		// Log a message to say that we're waiting for any background goroutines to
+		// complete their tasks, then call Wait() to block until our WaitGroup counter
+		// is zero.
+		app.logger.PrintInfo("completing background tasks", map[string]string{
+			"addr": srv.Addr,
+		})
+		app.wg.Wait()
+	}
+	close(stopFlushing)
+	app.flushViews()
+	return err
+}
+
+// The flushViews() method writes the buffered product view counts to the database,
+// logging any error.
+func (app *application) flushViews() {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	err := app.models.Views.Flush(ctx)
+	if err != nil {
+		app.logger.PrintError(err, nil)
+	}
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+)
+
+// The recordingViews type is a views model which keeps flushed counts in memory
+// instead of writing them to the database.
+type recordingViews struct {
+	mu        sync.Mutex
+	pending   map[int64]int64
+	persisted map[int64]int64
+}
+
+func newRecordingViews() *recordingViews {
+	return &recordingViews{pending: map[int64]int64{}, persisted: map[int64]int64{}}
+}
+
+func (m *recordingViews) Increment(productID int64) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.pending[productID]++
+}
+
+func (m *recordingViews) Flush(ctx context.Context) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for id, count := range m.pending {
+		m.persisted[id] += count
+		delete(m.pending, id)
+	}
+	return nil
+}
+
+func (m *recordingViews) assertPersisted(t *testing.T, want map[int64]int64) {
+	t.Helper()
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for id, count := range want {
+		if m.persisted[id] != count {
+			t.Errorf("product %d: got %d views persisted; want %d", id, m.persisted[id], count)
+		}
+	}
+	if len(m.pending) != 0 {
+		t.Errorf("got %d products with unflushed views; want 0", len(m.pending))
+	}
+}
+
+func TestShutdownFlushesViews(t *testing.T) {
+	app := newTestApplication(t)
+	views := newRecordingViews()
+	app.models.Views = views
+	views.Increment(1)
+	views.Increment(1)
+	views.Increment(2)
+
+	err := app.shutdown(context.Background(), &http.Server{}, make(chan struct{}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	views.assertPersisted(t, map[int64]int64{1: 2, 2: 1})
+}
+
+func TestShutdownFlushesViewsWhenShutdownFails(t *testing.T) {
+	app := newTestApplication(t)
+	views := newRecordingViews()
+	app.models.Views = views
+
+	// Start a server with a request which won't finish before the shutdown deadline.
+	started, finish := make(chan struct{}), make(chan struct{})
+	srv := &http.Server{Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		views.Increment(1)
+		close(started)
+		<-finish
+	})}
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go srv.Serve(ln)
+	defer close(finish)
+	go http.Get("http://" + ln.Addr().String())
+	<-started
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	err = app.shutdown(ctx, srv, make(chan struct{}))
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("got error %v; want %v", err, context.DeadlineExceeded)
+	}
+	views.assertPersisted(t, map[int64]int64{1: 1})
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"finalproject/internal/data"
+	"finalproject/internal/jsonlog"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// Create a newTestApplication helper which returns an instance of our application
+// struct containing mocked dependencies. Log output is discarded.
+func newTestApplication(t *testing.T) *application {
+	return &application{
+		logger: jsonlog.New(io.Discard, jsonlog.LevelOff),
+		models: data.NewMockModels(),
+	}
+}
+
+// The do() helper sends a request with the given method, path and client address
+// through a handler and returns the recorded response.
+func do(t *testing.T, h http.Handler, method, path, remoteAddr string) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(method, path, nil)
+	if remoteAddr != "" {
+		r.RemoteAddr = remoteAddr
+	}
+	rr := httptest.NewRecorder()
+	h.ServeHTTP(rr, r)
+	return rr
+}
+
+// The stubProducts type is a products model whose GetAll() returns a fixed set of
+// products and records the arguments it was called with, so that handler tests can
+// check how the query string was parsed.
+type stubProducts struct {
+	data.MockMovieModel
+	products []*data.Product
+	metadata data.Metadata
+	calls    *[]getAllCall
+}
+
+type getAllCall struct {
+	title      string
+	categories []string
+	tags       []string
+	specs      map[string]string
+	filters    data.Filters
+}
+
+func (m stubProducts) GetAll(title string, genres []string, tags []string, specs map[string]string, filters data.Filters, r *http.Request) ([]*data.Product, data.Metadata, error) {
+	if m.calls != nil {
+		*m.calls = append(*m.calls, getAllCall{title, genres, tags, specs, filters})
+	}
+	return m.products, m.metadata, nil
+}
+
+func (m stubProducts) Get(id int64, r *http.Request) (*data.Product, error) {
+	for _, product := range m.products {
+		if product.ID == id {
+			return product, nil
+		}
+	}
+	return nil, data.ErrRecordNotFound
+}
+
+func (m stubProducts) GetByUUID(uuid string, r *http.Request) (*data.Product, error) {
+	for _, product := range m.products {
+		if product.UUID == uuid {
+			return product, nil
+		}
+	}
+	return nil, data.ErrRecordNotFound
+}
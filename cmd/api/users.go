@@ -1,9 +1,9 @@
 package main
 
 import (
-	"awesomeProject2/internal/data"
-	"awesomeProject2/internal/validator"
 	"errors"
+	"finalproject/internal/data"
+	"finalproject/internal/validator"
 	"net/http"
 	"time"
 )
@@ -11,9 +11,10 @@ import (
 func (app *application) registerUserHandler(w http.ResponseWriter, r *http.Request) {
 	// Create an anonymous struct to hold the expected data from the request body.
 	var input struct {
-		Name     string `json:"name"`
-		Email    string `json:"email"`
-		Password string `json:"password"`
+		FirstName string `json:"firstName"`
+		LastName  string `json:"lastName"`
+		Email     string `json:"email"`
+		Password  string `json:"password"`
 	}
 	// Parse the request body into the anonymous struct.
 	err := app.readJSON(w, r, &input)
@@ -26,7 +27,8 @@ func (app *application) registerUserHandler(w http.ResponseWriter, r *http.Reque
 	// Activated field will have the zero-value of false by default. But setting this
 	// explicitly helps to make our intentions clear to anyone reading the code.
 	user := &data.User{
-		Name:      input.Name,
+		FirstName: input.FirstName,
+		LastName:  input.LastName,
 		Email:     input.Email,
 		Activated: false,
 	}
@@ -1,55 +1,217 @@
 package data
 
 import (
+	"encoding/base64"
+	"errors"
 	"finalproject/internal/validator"
 	"math"
+	"net/url"
+	"strconv"
 	"strings"
 )
 
+// The Cursor field holds an opaque "after" cursor from a previous page. When it is set,
+// results are paginated by keyset (continuing after the cursor's record) instead of by
+// offset, and Page is ignored.
 type Filters struct {
 	Page         int
 	PageSize     int
 	Sort         string
 	SortSafelist []string
+	Cursor       string
 }
 
-// Define a new Metadata struct for holding the pagination metadata.
+// Define a new Metadata struct for holding the pagination metadata. NextCursor is set
+// whenever the results are sorted by id and there may be a further page; it can be
+// passed back as the "after" cursor to fetch that page.
 type Metadata struct {
-	CurrentPage  int `json:"current_page,omitempty"`
-	PageSize     int `json:"page_size,omitempty"`
-	FirstPage    int `json:"first_page,omitempty"`
-	LastPage     int `json:"last_page,omitempty"`
-	TotalRecords int `json:"total_records,omitempty"`
-}
-
-// Check that the client-provided Sort field matches one of the entries in our safelist
-// and if it does, extract the column name from the Sort field by stripping the leading
-// hyphen character (if one exists).
-func (f Filters) sortColumn() string {
-	for _, safeValue := range f.SortSafelist {
-		if f.Sort == safeValue {
-			return strings.TrimPrefix(f.Sort, "-")
-		}
+	CurrentPage  int    `json:"current_page,omitempty"`
+	PageSize     int    `json:"page_size,omitempty"`
+	FirstPage    int    `json:"first_page,omitempty"`
+	LastPage     int    `json:"last_page,omitempty"`
+	TotalRecords int    `json:"total_records,omitempty"`
+	NextCursor   string `json:"next_cursor,omitempty"`
+	NextPage     string `json:"next_page,omitempty"`
+	PrevPage     string `json:"prev_page,omitempty"`
+}
+
+// The SetPageLinks() method fills in NextPage and PrevPage with links to the adjacent
+// pages, built from the URL of the current request so that all of its other query
+// parameters (filters, sort, page size) are preserved. Links are left empty when there
+// is no such page, or when the results aren't paginated by page number.
+func (m *Metadata) SetPageLinks(u *url.URL) {
+	if m.CurrentPage == 0 {
+		return
+	}
+	link := func(page int) string {
+		qs := u.Query()
+		qs.Set("page", strconv.Itoa(page))
+		return u.Path + "?" + qs.Encode()
+	}
+	if m.CurrentPage < m.LastPage {
+		m.NextPage = link(m.CurrentPage + 1)
+	}
+	if m.CurrentPage > m.FirstPage {
+		m.PrevPage = link(m.CurrentPage - 1)
+	}
+}
+
+var errInvalidCursor = errors.New("invalid cursor")
+
+// The encodeCursor() function returns an opaque cursor pointing after the record with
+// the given id.
+func encodeCursor(id int64) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(strconv.FormatInt(id, 10)))
+}
+
+// The decodeCursor() function returns the record id held in a cursor created by
+// encodeCursor().
+func decodeCursor(cursor string) (int64, error) {
+	b, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return 0, errInvalidCursor
 	}
-	panic("unsafe sort parameter: " + f.Sort)
+	id, err := strconv.ParseInt(string(b), 10, 64)
+	if err != nil || id < 1 {
+		return 0, errInvalidCursor
+	}
+	return id, nil
+}
+
+// SortSafelists is the single registry of sortable fields for each listable resource,
+// keyed by resource name. Handlers should use the relevant entry as their
+// Filters.SortSafelist instead of maintaining their own list, so that it can't drift
+// from the columns the model actually selects. To make a new field sortable, add it
+// here.
+var SortSafelists = map[string][]string{
+	"products": sortSafelist("id", "title", "runtime", "created_at", "relevance"),
+}
+
+// The sortSafelist() helper returns the ascending and descending ("-") sort values for
+// each of the given fields.
+func sortSafelist(fields ...string) []string {
+	safelist := make([]string, 0, 2*len(fields))
+	for _, field := range fields {
+		safelist = append(safelist, field, "-"+field)
+	}
+	return safelist
+}
+
+// Some fields are more useful sorted in descending order, so when the client provides
+// one of these without a sign we sort descending. An explicit "-" (descending) or "+"
+// (ascending) prefix always overrides the default. Note that clients need to send "+"
+// URL-encoded as %2B in the query string.
+var defaultSortDirections = map[string]string{
+	"created_at": "DESC",
+	"relevance":  "DESC",
+}
+
+// The Sort field may hold several comma-separated sort values, for example
+// "runtime,-created_at", which are applied in order.
+func (f Filters) sortValues() []string {
+	return strings.Split(f.Sort, ",")
+}
+
+// Check that a client-provided sort value matches one of the entries in our safelist
+// and if it does, extract the column name from it by stripping the leading "+" or "-"
+// character (if one exists).
+func (f Filters) sortColumn(value string) string {
+	if permittedSort(value, f.SortSafelist) {
+		return strings.TrimPrefix(strings.TrimPrefix(value, "+"), "-")
+	}
+	panic("unsafe sort parameter: " + value)
 }
 
 // Return the sort direction ("ASC" or "DESC") depending on the prefix character of the
-// Sort field.
-func (f Filters) sortDirection() string {
-	if strings.HasPrefix(f.Sort, "-") {
+// sort value, falling back to the field's default direction if it has no prefix.
+func (f Filters) sortDirection(value string) string {
+	switch {
+	case strings.HasPrefix(value, "-"):
 		return "DESC"
+	case strings.HasPrefix(value, "+"):
+		return "ASC"
+	}
+	if direction, ok := defaultSortDirections[value]; ok {
+		return direction
 	}
 	return "ASC"
 }
 
+// The orderBy() method returns the ORDER BY terms for all of the sort values, e.g.
+// ["runtime ASC", "created_at DESC"]. Sort fields which aren't plain columns are
+// replaced by their SQL expression from the expressions map, and a field whose
+// expression is empty is left out. Like sortColumn(), it panics if any of the values
+// isn't in the safelist, so ValidateFilters() must be called first.
+func (f Filters) orderBy(expressions map[string]string) []string {
+	var terms []string
+	for _, value := range f.sortValues() {
+		column := f.sortColumn(value)
+		if expression, ok := expressions[column]; ok {
+			if expression == "" {
+				continue
+			}
+			column = expression
+		}
+		terms = append(terms, column+" "+f.sortDirection(value))
+	}
+	return terms
+}
+
+// The permittedSort() helper reports whether a sort value is allowed by the safelist.
+// Values in the safelist are permitted as-is, and "+field" is also permitted whenever
+// the unsigned "field" is in the safelist.
+func permittedSort(value string, safelist []string) bool {
+	if strings.HasPrefix(value, "+") {
+		value = strings.TrimPrefix(value, "+")
+		if strings.HasPrefix(value, "-") {
+			return false
+		}
+	}
+	return validator.PermittedValue(value, safelist...)
+}
+
 func (f Filters) limit() int {
 	return f.PageSize
 }
 func (f Filters) offset() int {
+	if f.Cursor != "" {
+		return 0
+	}
 	return (f.Page - 1) * f.PageSize
 }
 
+// Keyset pagination needs a unique, stable sort key, so cursors are only supported
+// when the results are sorted by id alone.
+func (f Filters) cursorSortable() bool {
+	return f.Sort == "id" || f.Sort == "+id" || f.Sort == "-id"
+}
+
+// The keyset() method returns the id to continue after and the comparison operator to
+// use for it, given the sort direction. With no cursor the id is 0, which matches
+// every record in ascending order; callers should skip the predicate in that case.
+func (f Filters) keyset() (int64, string) {
+	if f.Cursor == "" {
+		return 0, ">"
+	}
+	id, err := decodeCursor(f.Cursor)
+	if err != nil {
+		panic("invalid cursor: " + f.Cursor)
+	}
+	if f.sortDirection(f.Sort) == "DESC" {
+		return id, "<"
+	}
+	return id, ">"
+}
+
+// The nextCursor() method returns the cursor for the page after one ending with the
+// record lastID, or "" if there is no further page or the sort doesn't support cursors.
+func (f Filters) nextCursor(lastID int64, count int) string {
+	if !f.cursorSortable() || count < f.PageSize || count == 0 {
+		return ""
+	}
+	return encodeCursor(lastID)
+}
+
 // The calculateMetadata() function calculates the appropriate pagination metadata
 // values given the total number of records, current page, and page size values. Note
 // that the last page value is calculated using the math.Ceil() function, which rounds
@@ -75,6 +237,18 @@ func ValidateFilters(v *validator.Validator, f Filters) {
 	v.Check(f.Page <= 10_000_000, "page", "must be a maximum of 10 million")
 	v.Check(f.PageSize > 0, "page_size", "must be greater than zero")
 	v.Check(f.PageSize <= 100, "page_size", "must be a maximum of 100")
-	// Check that the sort parameter matches a value in the safelist.
-	v.Check(validator.PermittedValue(f.Sort, f.SortSafelist...), "sort", "invalid sort value")
+	// Check that each component of the sort parameter matches a value in the safelist,
+	// and that no field is sorted on more than once.
+	columns := make([]string, 0, len(f.sortValues()))
+	for _, value := range f.sortValues() {
+		v.Check(permittedSort(value, f.SortSafelist), "sort", "invalid sort value")
+		columns = append(columns, strings.TrimPrefix(strings.TrimPrefix(value, "+"), "-"))
+	}
+	v.Check(validator.Unique(columns), "sort", "must not sort by the same field more than once")
+	// Check that a cursor, if provided, is one we issued and that the sort supports it.
+	if f.Cursor != "" {
+		_, err := decodeCursor(f.Cursor)
+		v.Check(err == nil, "after", "invalid cursor")
+		v.Check(f.cursorSortable(), "sort", "must be id or -id when using a cursor")
+	}
 }
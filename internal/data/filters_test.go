@@ -0,0 +1,233 @@
+package data
+
+import (
+	"finalproject/internal/validator"
+	"net/url"
+	"reflect"
+	"testing"
+)
+
+func TestSortDirection(t *testing.T) {
+	f := Filters{SortSafelist: SortSafelists["products"]}
+	tests := []struct {
+		value string
+		want  string
+	}{
+		{"title", "ASC"},
+		{"-title", "DESC"},
+		{"+title", "ASC"},
+		{"created_at", "DESC"},
+		{"-created_at", "DESC"},
+		{"+created_at", "ASC"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			if got := f.sortDirection(tt.value); got != tt.want {
+				t.Errorf("got %s; want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPermittedSort(t *testing.T) {
+	safelist := SortSafelists["products"]
+	for value, want := range map[string]bool{
+		"title":       true,
+		"-title":      true,
+		"+title":      true,
+		"+-title":     false,
+		"price":       false,
+		"title; DROP": false,
+	} {
+		if got := permittedSort(value, safelist); got != want {
+			t.Errorf("permittedSort(%q): got %t; want %t", value, got, want)
+		}
+	}
+}
+
+func TestOrderBy(t *testing.T) {
+	tests := []struct {
+		sort string
+		want []string
+	}{
+		{"title", []string{"title ASC"}},
+		{"-runtime", []string{"runtime DESC"}},
+		{"runtime,-title", []string{"runtime ASC", "title DESC"}},
+		{"created_at,+id", []string{"created_at DESC", "id ASC"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.sort, func(t *testing.T) {
+			f := Filters{Sort: tt.sort, SortSafelist: SortSafelists["products"]}
+			if got := f.orderBy(nil); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %q; want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidateFiltersSort(t *testing.T) {
+	tests := []struct {
+		sort  string
+		valid bool
+	}{
+		{"id", true},
+		{"-title", true},
+		{"runtime,-created_at", true},
+		{"runtime,+title,-id", true},
+		{"price", false},
+		{"runtime,price", false},
+		{"runtime,", false},
+		{"title,-title", false},
+		{"+id,id", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.sort, func(t *testing.T) {
+			v := validator.New()
+			ValidateFilters(v, Filters{Page: 1, PageSize: 20, Sort: tt.sort, SortSafelist: SortSafelists["products"]})
+			if v.Valid() != tt.valid {
+				t.Errorf("got valid %t; want %t (errors: %v)", v.Valid(), tt.valid, v.Errors)
+			}
+		})
+	}
+}
+
+func TestCursorRoundTrip(t *testing.T) {
+	for _, id := range []int64{1, 42, 9_007_199_254_740_993} {
+		got, err := decodeCursor(encodeCursor(id))
+		if err != nil || got != id {
+			t.Errorf("id %d: got %d, %v", id, got, err)
+		}
+	}
+	for _, cursor := range []string{"not base64!", encodeCursor(0), "YWJj"} {
+		if _, err := decodeCursor(cursor); err == nil {
+			t.Errorf("decodeCursor(%q): got nil error", cursor)
+		}
+	}
+}
+
+func TestKeyset(t *testing.T) {
+	cursor := encodeCursor(10)
+	tests := []struct {
+		name   string
+		sort   string
+		cursor string
+		id     int64
+		op     string
+		offset int
+	}{
+		{"Offset mode", "id", "", 0, ">", 20},
+		{"Ascending", "id", cursor, 10, ">", 0},
+		{"Descending", "-id", cursor, 10, "<", 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := Filters{Page: 2, PageSize: 20, Sort: tt.sort, Cursor: tt.cursor}
+			id, op := f.keyset()
+			if id != tt.id || op != tt.op {
+				t.Errorf("got keyset %d %s; want %d %s", id, op, tt.id, tt.op)
+			}
+			if got := f.offset(); got != tt.offset {
+				t.Errorf("got offset %d; want %d", got, tt.offset)
+			}
+		})
+	}
+}
+
+func TestNextCursor(t *testing.T) {
+	tests := []struct {
+		name  string
+		sort  string
+		count int
+		want  string
+	}{
+		{"Full page", "id", 2, encodeCursor(7)},
+		{"Full page descending", "-id", 2, encodeCursor(7)},
+		{"Last page", "id", 1, ""},
+		{"Empty page", "id", 0, ""},
+		{"Not sorted by id", "title", 2, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := Filters{PageSize: 2, Sort: tt.sort}
+			if got := f.nextCursor(7, tt.count); got != tt.want {
+				t.Errorf("got %q; want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidateFiltersCursor(t *testing.T) {
+	tests := []struct {
+		name   string
+		sort   string
+		cursor string
+		valid  bool
+	}{
+		{"Valid", "id", encodeCursor(5), true},
+		{"Descending", "-id", encodeCursor(5), true},
+		{"Invalid cursor", "id", "garbage", false},
+		{"Not sorted by id", "title", encodeCursor(5), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			v := validator.New()
+			ValidateFilters(v, Filters{Page: 1, PageSize: 20, Sort: tt.sort, SortSafelist: SortSafelists["products"], Cursor: tt.cursor})
+			if v.Valid() != tt.valid {
+				t.Errorf("got valid %t; want %t (errors: %v)", v.Valid(), tt.valid, v.Errors)
+			}
+		})
+	}
+}
+
+func TestSetPageLinks(t *testing.T) {
+	u, err := url.Parse("/v1/movies?title=laptop&categories=a,b&sort=-title&page=2&page_size=5")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name     string
+		metadata Metadata
+		next     string
+		prev     string
+	}{
+		{
+			name:     "Middle page",
+			metadata: calculateMetadata(15, 2, 5),
+			next:     "/v1/movies?categories=a%2Cb&page=3&page_size=5&sort=-title&title=laptop",
+			prev:     "/v1/movies?categories=a%2Cb&page=1&page_size=5&sort=-title&title=laptop",
+		},
+		{
+			name:     "First page",
+			metadata: calculateMetadata(15, 1, 5),
+			next:     "/v1/movies?categories=a%2Cb&page=2&page_size=5&sort=-title&title=laptop",
+		},
+		{
+			name:     "Last page",
+			metadata: calculateMetadata(15, 3, 5),
+			prev:     "/v1/movies?categories=a%2Cb&page=2&page_size=5&sort=-title&title=laptop",
+		},
+		{
+			name:     "Only page",
+			metadata: calculateMetadata(3, 1, 5),
+		},
+		{
+			name:     "No results",
+			metadata: calculateMetadata(0, 1, 5),
+		},
+		{
+			name:     "Cursor mode",
+			metadata: Metadata{PageSize: 5, NextCursor: encodeCursor(5)},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.metadata.SetPageLinks(u)
+			if tt.metadata.NextPage != tt.next {
+				t.Errorf("got next %q; want %q", tt.metadata.NextPage, tt.next)
+			}
+			if tt.metadata.PrevPage != tt.prev {
+				t.Errorf("got prev %q; want %q", tt.metadata.PrevPage, tt.prev)
+			}
+		})
+	}
+}
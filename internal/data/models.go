@@ -1,7 +1,9 @@
 package data
 
 import (
+	"context"
 	"errors"
+	"github.com/jackc/pgx/v5/pgconn"
 	"github.com/jackc/pgx/v5/pgxpool"
 	"net/http"
 	"time"
@@ -14,15 +16,27 @@ var (
 	ErrEditConflict   = errors.New("edit conflict")
 )
 
+// IsPoolTimeout reports whether err was caused by timing out while waiting to acquire
+// a connection from the pool, which means that the pool is exhausted. Timeouts while a
+// query is running are wrapped by pgconn, so they aren't reported as pool timeouts.
+func IsPoolTimeout(err error) bool {
+	return errors.Is(err, context.DeadlineExceeded) && !pgconn.Timeout(err)
+}
+
 // Create a Models struct which wraps the MovieModel. We'll add other models to this,
 // like a UserModel and PermissionModel, as our build progresses.
 type Models struct {
 	Products interface {
 		Insert(movie *Product, r *http.Request) error
 		Get(id int64, r *http.Request) (*Product, error)
+		GetByUUID(uuid string, r *http.Request) (*Product, error)
 		Update(movie *Product, r *http.Request) error
 		Delete(id int64, r *http.Request) error
-		GetAll(title string, genres []string, filters Filters, r *http.Request) ([]*Product, Metadata, error)
+		GetAll(title string, genres []string, tags []string, specs map[string]string, filters Filters, r *http.Request) ([]*Product, Metadata, error)
+	}
+	Views interface {
+		Increment(productID int64)
+		Flush(ctx context.Context) error
 	}
 	Users interface {
 		Insert(user *User, r *http.Request) error
@@ -38,9 +52,10 @@ type Models struct {
 }
 
 // For ease of use, we also add a New() method which returns a Models struct containing
-// the initialized MovieModel.
-func NewModels(db *pgxpool.Pool) Models {
-	m := MovieModel{DB: db}
+// the initialized MovieModel. The uniqueProductTitles parameter turns on the per-owner
+// product title uniqueness check.
+func NewModels(db *pgxpool.Pool, uniqueProductTitles bool) Models {
+	m := MovieModel{DB: db, UniqueTitlePerOwner: uniqueProductTitles}
 	u := UserModel{
 		DB: db,
 	}
@@ -49,6 +64,7 @@ func NewModels(db *pgxpool.Pool) Models {
 	}
 	return Models{
 		Products: m,
+		Views:    NewViewModel(db),
 		Users:    u,
 		Tokens:   t,
 	}
@@ -57,6 +73,7 @@ func NewModels(db *pgxpool.Pool) Models {
 func NewMockModels() Models {
 	return Models{
 		Products: MockMovieModel{},
+		Views:    MockViewModel{},
 		Users:    MockUserModel{},
 		Tokens:   MockTokenModel{},
 	}
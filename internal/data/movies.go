@@ -7,8 +7,10 @@ import (
 	"finalproject/internal/validator"
 	"fmt"
 	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
 	"github.com/jackc/pgx/v5/pgxpool"
 	"net/http"
+	"strings"
 	"time"
 )
 
@@ -16,33 +18,105 @@ type RatingSchema struct {
 	UserId string `json:"user_id"`
 	Rating int    `json:"rating"`
 }
+
+// Product is the JSON contract for product responses. Every field is always present in
+// the output, in declaration order, so that clients get the same shape for every
+// product: scalars are never omitted when zero, and slice and map fields are encoded
+// as [] and {} rather than null (see initSlices). CreatedAt is the only field which is
+// never serialized. Owner and OwnerName are filled in from the database by the model.
+// Nothing on this type stops them being decoded from JSON, so handlers which read a
+// product from the client should decode into their own input struct and set Owner
+// themselves.
 type Product struct {
-	ID          int64          `json:"id"`
-	CreatedAt   time.Time      `json:"-"`
-	Title       string         `json:"title"`
-	Owner       int64          `json:"owner"`
-	Description string         `json:"description"`
-	Runtime     Runtime        `json:"runtime,omitempty"`
-	Categories  []string       `json:"categories"`
-	Ratings     []RatingSchema `json:"ratings,omitempty"`
-	Version     string         `json:"version"`
+	ID             int64             `json:"id"`
+	UUID           string            `json:"uuid"`
+	CreatedAt      time.Time         `json:"-"`
+	Title          string            `json:"title"`
+	Owner          int64             `json:"owner"`
+	OwnerName      string            `json:"owner_name"`
+	Description    string            `json:"description"`
+	Runtime        Runtime           `json:"runtime"`
+	Categories     []string          `json:"categories"`
+	Tags           []string          `json:"tags"`
+	Specifications map[string]string `json:"specifications"`
+	Ratings        []RatingSchema    `json:"ratings"`
+	Version        string            `json:"version"`
+}
+
+// The initSlices() method replaces any nil slice (and map) fields with empty ones, so
+// that they are encoded as [] (or {}) instead of null. It should be called on every
+// product before it is returned from the model.
+func (p *Product) initSlices() {
+	if p.Categories == nil {
+		p.Categories = []string{}
+	}
+	if p.Tags == nil {
+		p.Tags = []string{}
+	}
+	if p.Specifications == nil {
+		p.Specifications = map[string]string{}
+	}
+	if p.Ratings == nil {
+		p.Ratings = []RatingSchema{}
+	}
 }
 
 func ValidateMovie(v *validator.Validator, product *Product) {
+	// Normalize whitespace in the free-text fields before checking them, so that the
+	// length checks apply to (and we store) the value the client actually meant.
+	product.Title = validator.NormalizeSpace(product.Title)
+	product.Description = validator.NormalizeSpace(product.Description)
+	for i := range product.Categories {
+		product.Categories[i] = validator.NormalizeSpace(product.Categories[i])
+	}
+	for i := range product.Tags {
+		product.Tags[i] = validator.NormalizeSpace(product.Tags[i])
+	}
 	v.Check(product.Title != "", "title", "must be provided")
 	v.Check(len(product.Title) <= 500, "title", "must not be more than 500 bytes long")
 	v.Check(product.Runtime != 0, "runtime", "must be provided")
 	v.Check(product.Runtime > 0, "runtime", "must be a positive integer")
 	v.Check(product.Categories != nil, "genres", "must be provided")
-	v.Check(product.Owner >= 0, "owner", "must be provided")
+	v.Check(product.Owner > 0, "owner", "must be provided")
 	v.Check(len(product.Categories) >= 1, "genres", "must contain at least 1 category")
 	v.Check(len(product.Categories) <= 5, "genres", "must not contain more than 5 genres")
 	v.Check(validator.Unique(product.Categories), "genres", "must not contain duplicate values")
+	// Tags are optional free-form labels, independent of categories.
+	v.Check(len(product.Tags) <= 10, "tags", "must not contain more than 10 tags")
+	v.Check(validator.Unique(product.Tags), "tags", "must not contain duplicate values")
+	for _, tag := range product.Tags {
+		v.Check(tag != "", "tags", "must not contain empty values")
+		v.Check(len(tag) <= 30, "tags", "must not contain values more than 30 bytes long")
+		v.Check(tag == strings.ToLower(tag), "tags", "must be lowercase")
+	}
+	// Specifications are optional key-value specs like "RAM": "8GB".
+	v.Check(len(product.Specifications) <= 30, "specifications", "must not contain more than 30 entries")
+	for key, value := range product.Specifications {
+		v.Check(key != "", "specifications", "must not contain empty keys")
+		v.Check(len(key) <= 50, "specifications", "must not contain keys more than 50 bytes long")
+		v.Check(value != "", "specifications", "must not contain empty values")
+		v.Check(len(value) <= 200, "specifications", "must not contain values more than 200 bytes long")
+	}
 }
 
-// Define a MovieModel struct type which wraps a sql.DB connection pool.
+// Define a custom ErrDuplicateProductTitle error.
+var (
+	ErrDuplicateProductTitle = errors.New("duplicate product title")
+)
+
+// Define a MovieModel struct type which wraps a sql.DB connection pool. When
+// UniqueTitlePerOwner is set, a seller can't have two products whose titles differ
+// only by case. Different sellers can still use the same title. The titleTaken() check
+// gives a clean error in the common case, but two concurrent writes can both pass it,
+// so turning the option on also requires the unique index:
+//
+//	CREATE UNIQUE INDEX products_owner_title_idx ON products (owner, lower(title))
+//	WHERE deleted_at IS NULL;
+//
+// A violation of that index is reported as ErrDuplicateProductTitle too.
 type MovieModel struct {
-	DB *pgxpool.Pool
+	DB                  *pgxpool.Pool
+	UniqueTitlePerOwner bool
 }
 
 func (m MovieModel) Insert(movie *Product, r *http.Request) error {
@@ -53,22 +127,44 @@ func (m MovieModel) Get(id int64, r *http.Request) (*Product, error) {
 	if id < 1 {
 		return nil, ErrRecordNotFound
 	}
+	return m.getWhere("id = $1", id, r)
+}
+
+// The GetByUUID() method fetches a product by its public UUID. External clients should
+// use the UUID, which doesn't reveal how many products exist, while the int64 id stays
+// in use for internal joins.
+func (m MovieModel) GetByUUID(uuid string, r *http.Request) (*Product, error) {
+	return m.getWhere("uuid = $1", uuid, r)
+}
+
+// The getWhere() method fetches a single product matching the given WHERE condition,
+// which must use $1 for its only argument. Soft-deleted products are never returned.
+func (m MovieModel) getWhere(condition string, arg any, r *http.Request) (*Product, error) {
 	// Define the SQL query for retrieving the movie data.
-	query := `SELECT id, created_at, title, year, runtime, genres, version
+	// The owner's name is resolved with a subquery so that products whose owner no
+	// longer exists still come back, with an empty owner name.
+	query := `SELECT id, uuid, created_at, title, owner,
+				COALESCE((SELECT users.firstName || ' ' || users.lastName FROM users WHERE users.id = products.owner), ''), COALESCE(description, ''),
+				runtime, genres, tags, specifications, version
 				FROM products
-					WHERE id = $1`
+					WHERE deleted_at IS NULL AND ` + condition
 	// Declare a Movie struct to hold the data returned by the query.
 	var movie Product
 	ctx, cancel := context.WithTimeout(r.Context(), 3*time.Second)
 	defer cancel()
 
-	err := m.DB.QueryRow(ctx, query, id).Scan(
+	err := m.DB.QueryRow(ctx, query, arg).Scan(
 		&movie.ID,
+		&movie.UUID,
 		&movie.CreatedAt,
 		&movie.Title,
-		//&movie.Year,
+		&movie.Owner,
+		&movie.OwnerName,
+		&movie.Description,
 		&movie.Runtime,
-		//&movie.Genres,
+		&movie.Categories,
+		&movie.Tags,
+		&movie.Specifications,
 		&movie.Version,
 	)
 	if err != nil {
@@ -81,6 +177,7 @@ func (m MovieModel) Get(id int64, r *http.Request) (*Product, error) {
 			return nil, err
 		}
 	}
+	movie.initSlices()
 	// Otherwise, return a pointer to the Movie struct.
 	return &movie, nil
 }
@@ -91,21 +188,31 @@ func (m MovieModel) Update(movie *Product, r *http.Request) error {
 	// number.
 	query := `
 		UPDATE products
-			SET title = $1, year = $2, runtime = $3, genres = $4, version = uuid_generate_v4()
-		WHERE id = $5 AND version = $6
+			SET title = $1, runtime = $2, genres = $3, tags = $4, specifications = $5, version = uuid_generate_v4()
+		WHERE id = $6 AND version = $7 AND deleted_at IS NULL
 		RETURNING version`
 	// Create an args slice containing the values for the placeholder parameters.
 	args := []any{
 		movie.Title,
-		//movie.Year,
 		movie.Runtime,
-		//movie.Genres,
+		movie.Categories,
+		movie.Tags,
+		movie.Specifications,
 		movie.ID,
 		movie.Version,
 	}
 	ctx, cancel := context.WithTimeout(r.Context(), 3*time.Second)
 	defer cancel()
 
+	if m.UniqueTitlePerOwner {
+		taken, err := m.titleTaken(ctx, movie)
+		if err != nil {
+			return err
+		}
+		if taken {
+			return ErrDuplicateProductTitle
+		}
+	}
 	err := m.DB.QueryRow(ctx, query, args...).Scan(&movie.Version)
 	if err != nil {
 		switch {
@@ -113,6 +220,8 @@ func (m MovieModel) Update(movie *Product, r *http.Request) error {
 			return ErrEditConflict
 		case errors.Is(err, pgx.ErrNoRows):
 			return ErrEditConflict
+		case isTitleConflict(err):
+			return ErrDuplicateProductTitle
 		default:
 			return err
 		}
@@ -120,65 +229,116 @@ func (m MovieModel) Update(movie *Product, r *http.Request) error {
 	return nil
 }
 
-// Add a placeholder method for deleting a specific record from the movies table.
+// The isTitleConflict() helper reports whether err is a violation of the
+// products_owner_title_idx unique index.
+func isTitleConflict(err error) bool {
+	var pgErr *pgconn.PgError
+	return errors.As(err, &pgErr) && pgErr.Code == "23505" && pgErr.ConstraintName == "products_owner_title_idx"
+}
+
+// The titleTaken() method reports whether another product belonging to the same owner
+// already has the given title, ignoring case.
+func (m MovieModel) titleTaken(ctx context.Context, movie *Product) (bool, error) {
+	query := `
+		SELECT EXISTS(
+			SELECT 1 FROM products
+			WHERE owner = $1 AND lower(title) = lower($2) AND id <> $3 AND deleted_at IS NULL
+		)`
+	var taken bool
+	err := m.DB.QueryRow(ctx, query, movie.Owner, movie.Title, movie.ID).Scan(&taken)
+	return taken, err
+}
+
+// The Delete() method soft-deletes a product by setting its deleted_at timestamp,
+// rather than removing the row, so that historical records which reference the
+// product stay intact.
 func (m MovieModel) Delete(id int64, r *http.Request) error {
 	// Return an ErrRecordNotFound error if the movie ID is less than 1.
 	if id < 1 {
-		return pgx.ErrNoRows
+		return ErrRecordNotFound
 	}
-	// Construct the SQL query to delete the record.
+	// Construct the SQL query to soft-delete the record.
 	query := `
-		DELETE FROM Product
-			WHERE id = $1`
-	// Execute the SQL query using the Exec() method, passing in the id variable as
-	// the value for the placeholder parameter. The Exec() method returns a sql.Result
-	// object.
-	var movie Product
+		UPDATE products
+			SET deleted_at = now()
+		WHERE id = $1 AND deleted_at IS NULL`
 	ctx, cancel := context.WithTimeout(r.Context(), 3*time.Second)
 	defer cancel()
-	rows := m.DB.QueryRow(ctx, query, id)
-	err := rows.Scan(
-		&movie.ID,
-		&movie.CreatedAt,
-		&movie.Title,
-		//&movie.Year,
-		&movie.Runtime,
-		//&movie.Genres,
-		&movie.Version)
+	result, err := m.DB.Exec(ctx, query, id)
 	if err != nil {
-		switch {
-		case errors.Is(err, pgx.ErrNoRows):
-			return ErrRecordNotFound
-		case errors.Is(err, sql.ErrNoRows):
-			return ErrRecordNotFound
-		default:
-			return err
-		}
+		return err
+	}
+	// If no rows were affected, we know that the products table didn't contain a
+	// (not yet deleted) record with the provided ID at the moment we tried to delete
+	// it. In that case we return an ErrRecordNotFound error.
+	if result.RowsAffected() == 0 {
+		return ErrRecordNotFound
 	}
-	// If no rows were affected, we know that the movies table didn't contain a record
-	// with the provided ID at the moment we tried to delete it. In that case we
-	// return an ErrRecordNotFound error.
-
 	return nil
 }
 
+// The productSearchVector expression is the full-text search document for a product.
+// It covers both the title and the description, with title matches weighted above
+// description matches so that they rank higher (see productOrderBy).
+const productSearchVector = `(setweight(to_tsvector('simple', title), 'A') || setweight(to_tsvector('simple', coalesce(description, '')), 'B'))`
+
+// The productOrderBy() function returns the ORDER BY clause for a product listing.
+// Sorting by "relevance" ranks products by how well they match the title search, and
+// when there is a search term the rank also breaks ties between products which sort
+// equally, followed by the id. Without a search term every product ranks the same,
+// so ts_rank is skipped.
+func productOrderBy(title string, filters Filters) string {
+	rank := ""
+	if title != "" {
+		rank = fmt.Sprintf("ts_rank(%s, plainto_tsquery('simple', $1))", productSearchVector)
+	}
+	terms := filters.orderBy(map[string]string{"relevance": rank})
+	sorted := make(map[string]bool)
+	for _, value := range filters.sortValues() {
+		sorted[filters.sortColumn(value)] = true
+	}
+	if rank != "" && !sorted["relevance"] {
+		terms = append(terms, rank+" DESC")
+	}
+	if !sorted["id"] {
+		terms = append(terms, "id ASC")
+	}
+	return strings.Join(terms, ", ")
+}
+
 // Create a new GetAll() method which returns a slice of movies. Although we're not
 // using them right now, we've set this up to accept the various filter parameters as
-// arguments.
-func (m MovieModel) GetAll(title string, genres []string, filters Filters, r *http.Request) ([]*Product, Metadata, error) {
+// arguments. The tags filter matches products which have at least one of the given
+// tags, the specs filter matches products which have all of the given key-value
+// specifications. Soft-deleted products are never returned. Results are paginated by
+// offset, or by keyset if filters has a cursor.
+func (m MovieModel) GetAll(title string, genres []string, tags []string, specs map[string]string, filters Filters, r *http.Request) ([]*Product, Metadata, error) {
+	// If a cursor was provided, only return the records after it in the sort order.
+	afterID, keysetOp := filters.keyset()
 	// Construct the SQL query to retrieve all movie records.
 	query := fmt.Sprintf(`
-					SELECT count(*) OVER(), id, created_at, title, year, runtime, genres, version
+					SELECT count(*) OVER(), id, uuid, created_at, title, owner,
+					COALESCE((SELECT users.firstName || ' ' || users.lastName FROM users WHERE users.id = products.owner), ''), COALESCE(description, ''),
+					runtime, genres, tags, specifications, version
 					FROM products
-					WHERE (to_tsvector('simple', title) @@ plainto_tsquery('simple', $1) OR $1 = '')
+					WHERE (%s @@ plainto_tsquery('simple', $1) OR $1 = '')
 					AND (genres @> $2 OR $2 = '{}')
-					ORDER BY %s %s, id ASC
-					LIMIT $3 OFFSET $4`, filters.sortColumn(), filters.sortDirection())
+					AND (tags && $3 OR $3 = '{}')
+					AND (specifications @> $4 OR $4 = '{}')
+					AND deleted_at IS NULL
+					AND ($5 = 0 OR id %s $5)
+					ORDER BY %s
+					LIMIT $6 OFFSET $7`, productSearchVector, keysetOp, productOrderBy(title, filters))
 
 	// Create a context with a 3-second timeout.
 	ctx, cancel := context.WithTimeout(r.Context(), 3*time.Second)
 	defer cancel()
-	args := []any{title, genres, filters.limit(), filters.offset()}
+	// A nil map would be encoded as JSON null rather than {}, which would match no
+	// products at all, so make sure we always send an object.
+	if specs == nil {
+		specs = map[string]string{}
+	}
+	args := []any{title, genres, tags, specs, afterID, filters.limit(), filters.offset()}
 	// Use QueryContext() to execute the query. This returns a sql.Rows resultset
 	// containing the result.
 	rows, err := m.DB.Query(ctx, query, args...)
@@ -198,17 +358,23 @@ func (m MovieModel) GetAll(title string, genres []string, filters Filters, r *ht
 		err := rows.Scan(
 			&totalRecords,
 			&movie.ID,
+			&movie.UUID,
 			&movie.CreatedAt,
 			&movie.Title,
-			//&movie.Year,
+			&movie.Owner,
+			&movie.OwnerName,
+			&movie.Description,
 			&movie.Runtime,
-			//&movie.Genres,
+			&movie.Categories,
+			&movie.Tags,
+			&movie.Specifications,
 			&movie.Version,
 		)
 
 		if err != nil {
 			return nil, Metadata{}, err // Update this to return an empty Metadata struct.
 		}
+		movie.initSlices()
 		movies = append(movies, &movie)
 	}
 	if err = rows.Err(); err != nil {
@@ -217,6 +383,14 @@ func (m MovieModel) GetAll(title string, genres []string, filters Filters, r *ht
 	// Generate a Metadata struct, passing in the total record count and pagination
 	// parameters from the client.
 	metadata := calculateMetadata(totalRecords, filters.Page, filters.PageSize)
+	// When paginating by cursor the total only counts the records after the cursor,
+	// so page numbers don't apply.
+	if filters.Cursor != "" {
+		metadata = Metadata{PageSize: filters.PageSize}
+	}
+	if len(movies) > 0 {
+		metadata.NextCursor = filters.nextCursor(movies[len(movies)-1].ID, len(movies))
+	}
 	// Include the metadata struct when returning.
 	return movies, metadata, nil
 }
@@ -231,6 +405,10 @@ func (m MockMovieModel) Get(id int64, r *http.Request) (*Product, error) {
 	// Mock the action...
 	return nil, nil
 }
+func (m MockMovieModel) GetByUUID(uuid string, r *http.Request) (*Product, error) {
+	// Mock the action...
+	return nil, nil
+}
 func (m MockMovieModel) Update(movie *Product, r *http.Request) error {
 	// Mock the action...
 	return nil
@@ -239,6 +417,6 @@ func (m MockMovieModel) Delete(id int64, r *http.Request) error {
 	// Mock the action...
 	return nil
 }
-func (m MockMovieModel) GetAll(title string, genres []string, filters Filters, r *http.Request) ([]*Product, Metadata, error) {
+func (m MockMovieModel) GetAll(title string, genres []string, tags []string, specs map[string]string, filters Filters, r *http.Request) ([]*Product, Metadata, error) {
 	return nil, Metadata{}, nil
 }
@@ -0,0 +1,259 @@
+package data
+
+import (
+	"encoding/json"
+	"errors"
+	"finalproject/internal/validator"
+	"flag"
+	"fmt"
+	"github.com/jackc/pgx/v5/pgconn"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+var update = flag.Bool("update", false, "update golden files")
+
+// The assertGolden() helper compares got against the contents of testdata/name,
+// rewriting the file instead when the tests are run with -update.
+func assertGolden(t *testing.T, name string, got []byte) {
+	t.Helper()
+	path := filepath.Join("testdata", name)
+	if *update {
+		if err := os.WriteFile(path, got, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	want, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != string(want) {
+		t.Errorf("got:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestProductJSON(t *testing.T) {
+	tests := []struct {
+		name    string
+		product Product
+	}{
+		{
+			name: "product_full.golden",
+			product: Product{
+				ID:             1,
+				UUID:           "0b6c3a52-7f1e-4f4e-9a43-3c1f0e6d2a10",
+				CreatedAt:      time.Date(2023, 4, 1, 9, 30, 0, 0, time.UTC),
+				Title:          "Laptop",
+				Owner:          7,
+				OwnerName:      "Alice Smith",
+				Description:    "A light laptop",
+				Runtime:        120,
+				Categories:     []string{"electronics", "computers"},
+				Tags:           []string{"summer", "sale"},
+				Specifications: map[string]string{"RAM": "8GB", "CPU": "M2"},
+				Ratings:        []RatingSchema{{UserId: "3", Rating: 5}},
+				Version:        "5c4a2f0e-3b8d-4c55-8f1d-1a2b3c4d5e6f",
+			},
+		},
+		{
+			name:    "product_empty.golden",
+			product: Product{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.product.initSlices()
+			got, err := json.MarshalIndent(tt.product, "", "\t")
+			if err != nil {
+				t.Fatal(err)
+			}
+			assertGolden(t, tt.name, append(got, '\n'))
+		})
+	}
+}
+
+// The validProduct() helper returns a product which passes ValidateMovie(), for tests
+// to modify.
+func validProduct() *Product {
+	return &Product{
+		Title:      "Laptop",
+		Owner:      1,
+		Runtime:    120,
+		Categories: []string{"electronics"},
+	}
+}
+
+func TestValidateMovieTags(t *testing.T) {
+	tests := []struct {
+		name  string
+		tags  []string
+		valid bool
+	}{
+		{"None", nil, true},
+		{"Lowercase", []string{"summer", "vegan"}, true},
+		{"Leading space", []string{" summer"}, true},
+		{"Uppercase", []string{"Summer"}, false},
+		{"Duplicate", []string{"vegan", "vegan"}, false},
+		{"Duplicate after trimming", []string{"vegan", " vegan "}, false},
+		{"Empty", []string{""}, false},
+		{"Blank", []string{"   "}, false},
+		{"Too long", []string{strings.Repeat("a", 31)}, false},
+		{"Too many", strings.Split("a,b,c,d,e,f,g,h,i,j,k", ","), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			product := validProduct()
+			product.Tags = tt.tags
+			v := validator.New()
+			ValidateMovie(v, product)
+			if v.Valid() != tt.valid {
+				t.Errorf("got valid %t; want %t (errors: %v)", v.Valid(), tt.valid, v.Errors)
+			}
+		})
+	}
+}
+
+func TestValidateMovieNormalizesTags(t *testing.T) {
+	product := validProduct()
+	product.Tags = []string{" summer ", "gluten  free"}
+	ValidateMovie(validator.New(), product)
+	want := []string{"summer", "gluten free"}
+	if !reflect.DeepEqual(product.Tags, want) {
+		t.Errorf("got %q; want %q", product.Tags, want)
+	}
+}
+
+func TestIsTitleConflict(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{"Title index", &pgconn.PgError{Code: "23505", ConstraintName: "products_owner_title_idx"}, true},
+		{"Wrapped", fmt.Errorf("update: %w", &pgconn.PgError{Code: "23505", ConstraintName: "products_owner_title_idx"}), true},
+		{"Other index", &pgconn.PgError{Code: "23505", ConstraintName: "products_uuid_key"}, false},
+		{"Other error", errors.New("boom"), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := isTitleConflict(tt.err); got != tt.want {
+				t.Errorf("got %t; want %t", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidateMovieNormalizesText(t *testing.T) {
+	product := validProduct()
+	product.Title = "  iPhone   14 "
+	product.Description = "\tA  phone\n"
+	product.Categories = []string{" phones ", "smart  phones"}
+	v := validator.New()
+	ValidateMovie(v, product)
+	if !v.Valid() {
+		t.Fatalf("got errors %v", v.Errors)
+	}
+	if product.Title != "iPhone 14" {
+		t.Errorf("title: got %q", product.Title)
+	}
+	if product.Description != "A phone" {
+		t.Errorf("description: got %q", product.Description)
+	}
+	if want := []string{"phones", "smart phones"}; !reflect.DeepEqual(product.Categories, want) {
+		t.Errorf("categories: got %q; want %q", product.Categories, want)
+	}
+}
+
+func TestValidateMovieChecksNormalizedTitle(t *testing.T) {
+	tests := []struct {
+		name  string
+		title string
+		valid bool
+	}{
+		{"Blank", "   ", false},
+		{"Padded to over 500 bytes", "  " + strings.Repeat("a", 500) + "  ", true},
+		{"Over 500 bytes", strings.Repeat("a", 501), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			product := validProduct()
+			product.Title = tt.title
+			v := validator.New()
+			ValidateMovie(v, product)
+			if v.Valid() != tt.valid {
+				t.Errorf("got valid %t; want %t (errors: %v)", v.Valid(), tt.valid, v.Errors)
+			}
+		})
+	}
+}
+
+func TestValidateMovieRejectsCategoriesDuplicatedByWhitespace(t *testing.T) {
+	product := validProduct()
+	product.Categories = []string{"phones", " phones"}
+	v := validator.New()
+	ValidateMovie(v, product)
+	if _, ok := v.Errors["genres"]; !ok {
+		t.Errorf("got errors %v; want a genres error", v.Errors)
+	}
+}
+
+func TestValidateMovieSpecifications(t *testing.T) {
+	tooMany := map[string]string{}
+	for i := 0; i < 31; i++ {
+		tooMany[fmt.Sprintf("key%d", i)] = "value"
+	}
+	tests := []struct {
+		name  string
+		specs map[string]string
+		valid bool
+	}{
+		{"None", nil, true},
+		{"Valid", map[string]string{"RAM": "8GB", "Color": "Space Gray"}, true},
+		{"Empty key", map[string]string{"": "8GB"}, false},
+		{"Empty value", map[string]string{"RAM": ""}, false},
+		{"Long key", map[string]string{strings.Repeat("k", 51): "8GB"}, false},
+		{"Long value", map[string]string{"RAM": strings.Repeat("v", 201)}, false},
+		{"Too many", tooMany, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			product := validProduct()
+			product.Specifications = tt.specs
+			v := validator.New()
+			ValidateMovie(v, product)
+			if v.Valid() != tt.valid {
+				t.Errorf("got valid %t; want %t (errors: %v)", v.Valid(), tt.valid, v.Errors)
+			}
+		})
+	}
+}
+
+func TestProductOrderBy(t *testing.T) {
+	rank := "ts_rank(" + productSearchVector + ", plainto_tsquery('simple', $1))"
+	tests := []struct {
+		name  string
+		title string
+		sort  string
+		want  string
+	}{
+		{"No search", "", "id", "id ASC"},
+		{"No search by relevance", "", "relevance", "id ASC"},
+		{"No search multi-column", "", "-runtime,title", "runtime DESC, title ASC, id ASC"},
+		{"Search by relevance", "waterproof", "relevance", rank + " DESC, id ASC"},
+		{"Search by title", "waterproof", "title", "title ASC, " + rank + " DESC, id ASC"},
+		{"Search by id", "waterproof", "-id", "id DESC, " + rank + " DESC"},
+		{"Search by ascending relevance", "waterproof", "+relevance", rank + " ASC, id ASC"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			filters := Filters{Sort: tt.sort, SortSafelist: SortSafelists["products"]}
+			if got := productOrderBy(tt.title, filters); got != tt.want {
+				t.Errorf("got %q; want %q", got, tt.want)
+			}
+		})
+	}
+}
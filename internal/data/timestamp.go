@@ -0,0 +1,51 @@
+package data
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+)
+
+// Declare a custom Timestamp type for times which are included in JSON responses. It
+// has the underlying type time.Time, so database values can be scanned into it via a
+// (*time.Time) conversion.
+type Timestamp time.Time
+
+// The timestamp formats which can be used for JSON output.
+const (
+	TimestampRFC3339     = "rfc3339"
+	TimestampRFC3339Nano = "rfc3339nano"
+	TimestampUnix        = "unix"
+)
+
+// TimestampFormat controls how every Timestamp is written to JSON. It defaults to
+// RFC3339 without sub-second precision, which some clients mishandle, and is set once
+// at startup from the -json-timestamp-format flag.
+var TimestampFormat = TimestampRFC3339
+
+// The SetTimestampFormat() function sets TimestampFormat, returning an error if the
+// format isn't one of the supported values.
+func SetTimestampFormat(format string) error {
+	switch format {
+	case TimestampRFC3339, TimestampRFC3339Nano, TimestampUnix:
+		TimestampFormat = format
+		return nil
+	default:
+		return fmt.Errorf("invalid timestamp format %q", format)
+	}
+}
+
+// Implement a MarshalJSON() method on the Timestamp type so that it is written in the
+// configured format. Unix timestamps are written as a JSON number of seconds, and the
+// RFC3339 formats as strings.
+func (t Timestamp) MarshalJSON() ([]byte, error) {
+	tm := time.Time(t)
+	switch TimestampFormat {
+	case TimestampUnix:
+		return []byte(strconv.FormatInt(tm.Unix(), 10)), nil
+	case TimestampRFC3339Nano:
+		return []byte(strconv.Quote(tm.Format(time.RFC3339Nano))), nil
+	default:
+		return []byte(strconv.Quote(tm.Format(time.RFC3339))), nil
+	}
+}
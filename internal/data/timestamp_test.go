@@ -0,0 +1,51 @@
+package data
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+func TestTimestampMarshalJSON(t *testing.T) {
+	defer func(format string) { TimestampFormat = format }(TimestampFormat)
+	ts := Timestamp(time.Date(2023, 5, 1, 12, 30, 45, 123456789, time.UTC))
+
+	tests := []struct {
+		format string
+		want   string
+	}{
+		{TimestampRFC3339, `"2023-05-01T12:30:45Z"`},
+		{TimestampRFC3339Nano, `"2023-05-01T12:30:45.123456789Z"`},
+		{TimestampUnix, `1682944245`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.format, func(t *testing.T) {
+			if err := SetTimestampFormat(tt.format); err != nil {
+				t.Fatal(err)
+			}
+			got, err := json.Marshal(ts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(got) != tt.want {
+				t.Errorf("got %s; want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTimestampDefaultFormat(t *testing.T) {
+	if TimestampFormat != TimestampRFC3339 {
+		t.Errorf("got default format %q; want %q", TimestampFormat, TimestampRFC3339)
+	}
+}
+
+func TestSetTimestampFormatInvalid(t *testing.T) {
+	defer func(format string) { TimestampFormat = format }(TimestampFormat)
+	if err := SetTimestampFormat("iso8601"); err == nil {
+		t.Error("got nil error; want error")
+	}
+	if TimestampFormat != TimestampRFC3339 {
+		t.Errorf("format changed to %q", TimestampFormat)
+	}
+}
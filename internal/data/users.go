@@ -18,7 +18,7 @@ type CartItem struct {
 }
 type User struct {
 	ID          int64      `json:"id"`
-	CreatedAt   time.Time  `json:"created_at"`
+	CreatedAt   Timestamp  `json:"created_at"`
 	PhoneNumber string     `json:"phoneNumber"`
 	Address     string     `json:"address"`
 	FirstName   string     `json:"firstName"`
@@ -124,7 +124,7 @@ RETURNING id, created_at, version`
 	// to perform the insert there will be a violation of the UNIQUE "users_email_key"
 	// constraint that we set up in the previous chapter. We check for this error
 	// specifically, and return custom ErrDuplicateEmail error instead.
-	err := m.DB.QueryRow(ctx, query, args...).Scan(&user.ID, &user.CreatedAt, &user.Version)
+	err := m.DB.QueryRow(ctx, query, args...).Scan(&user.ID, (*time.Time)(&user.CreatedAt), &user.Version)
 	if err != nil {
 		switch {
 		case err.Error() == `ERROR: duplicate key value violates unique constraint "users_email_key" (SQLSTATE 23505)`:
@@ -146,7 +146,7 @@ WHERE email = $1`
 	defer cancel()
 	err := m.DB.QueryRow(ctx, query, email).Scan(
 		&user.ID,
-		&user.CreatedAt,
+		(*time.Time)(&user.CreatedAt),
 		&user.FirstName,
 		&user.LastName,
 		&user.Email,
@@ -225,7 +225,7 @@ AND tokens.expiry > $3`
 	// record is found we return an ErrRecordNotFound error.
 	err := m.DB.QueryRow(ctx, query, args...).Scan(
 		&user.ID,
-		&user.CreatedAt,
+		(*time.Time)(&user.CreatedAt),
 		&user.FirstName,
 		&user.LastName,
 		&user.Email,
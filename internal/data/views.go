@@ -0,0 +1,76 @@
+package data
+
+import (
+	"context"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"sync"
+)
+
+// Define a ViewModel type which counts product views in memory and periodically
+// writes them to the database in a single batch, rather than issuing an UPDATE for
+// every request. The counts map is protected by the mutex.
+type ViewModel struct {
+	DB     *pgxpool.Pool
+	mu     *sync.Mutex
+	counts map[int64]int64
+}
+
+// NewViewModel returns a ViewModel with an empty set of pending counts.
+func NewViewModel(db *pgxpool.Pool) ViewModel {
+	return ViewModel{
+		DB:     db,
+		mu:     &sync.Mutex{},
+		counts: make(map[int64]int64),
+	}
+}
+
+// Increment records a single view of a product. It doesn't touch the database.
+func (m ViewModel) Increment(productID int64) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.counts[productID]++
+}
+
+// Flush adds the pending view counts to the products table and resets them. If there
+// are no pending counts it does nothing. If the update fails, the counts are merged
+// back so that they are retried on the next flush instead of being lost.
+func (m ViewModel) Flush(ctx context.Context) error {
+	m.mu.Lock()
+	if len(m.counts) == 0 {
+		m.mu.Unlock()
+		return nil
+	}
+	ids := make([]int64, 0, len(m.counts))
+	views := make([]int64, 0, len(m.counts))
+	for id, count := range m.counts {
+		ids = append(ids, id)
+		views = append(views, count)
+	}
+	for id := range m.counts {
+		delete(m.counts, id)
+	}
+	m.mu.Unlock()
+	query := `
+		UPDATE products
+		SET views = products.views + pending.count
+		FROM unnest($1::bigint[], $2::bigint[]) AS pending(id, count)
+		WHERE products.id = pending.id`
+	_, err := m.DB.Exec(ctx, query, ids, views)
+	if err != nil {
+		m.mu.Lock()
+		for i, id := range ids {
+			m.counts[id] += views[i]
+		}
+		m.mu.Unlock()
+		return err
+	}
+	return nil
+}
+
+type MockViewModel struct{}
+
+func (m MockViewModel) Increment(productID int64) {}
+
+func (m MockViewModel) Flush(ctx context.Context) error {
+	return nil
+}
@@ -2,6 +2,7 @@ package validator
 
 import (
 	"regexp"
+	"strings"
 )
 
 // Declare a regular expression for sanity checking the format of email addresses (we'll
@@ -11,6 +12,8 @@ import (
 // note further down the page.
 var (
 	EmailRX = regexp.MustCompile("^[a-zA-Z0-9.!#$%&'*+\\/=?^_`{|}~-]+@[a-zA-Z0-9](?:[a-zA-Z0-9-]{0,61}[a-zA-Z0-9])?(?:\\.[a-zA-Z0-9](?:[a-zA-Z0-9-]{0,61}[a-zA-Z0-9])?)*$")
+	// UUIDRX matches a UUID in its canonical hyphenated form.
+	UUIDRX = regexp.MustCompile("^[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}$")
 )
 
 // Define a new Validator type which contains a map of validation errors.
@@ -66,3 +69,9 @@ func Unique[T comparable](values []T) bool {
 	}
 	return len(values) == len(uniqueValues)
 }
+
+// NormalizeSpace returns the string with leading and trailing whitespace removed and
+// every internal run of whitespace collapsed to a single space.
+func NormalizeSpace(value string) string {
+	return strings.Join(strings.Fields(value), " ")
+}
@@ -0,0 +1,27 @@
+package validator
+
+import (
+	"testing"
+)
+
+func TestNormalizeSpace(t *testing.T) {
+	tests := []struct {
+		name  string
+		value string
+		want  string
+	}{
+		{"Unchanged", "iPhone 14", "iPhone 14"},
+		{"Leading", "  iPhone", "iPhone"},
+		{"Trailing", "iPhone \t", "iPhone"},
+		{"Collapsed", "iPhone   14\n Pro", "iPhone 14 Pro"},
+		{"Blank", " \t\n ", ""},
+		{"Empty", "", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := NormalizeSpace(tt.value); got != tt.want {
+				t.Errorf("got %q; want %q", got, tt.want)
+			}
+		})
+	}
+}